@@ -5,10 +5,17 @@ import (
 	"cpp-package-manager/pkg/config"
 	"cpp-package-manager/pkg/resolver"
 	"cpp-package-manager/pkg/types"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 )
 
+// exitLockfileOutOfDate is the exit code of a frozen install whose
+// cppkg.lock does not match cppkg.json.
+const exitLockfileOutOfDate = 2
+
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
@@ -24,9 +31,11 @@ func main() {
 	case "install":
 		handleInstall(args)
 	case "upgrade":
-		handleUpgrade()
+		handleUpgrade(args)
 	case "uninstall":
 		handleUninstall(args)
+	case "verify":
+		handleVerify(args)
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		printUsage()
@@ -51,45 +60,207 @@ func handleInit() {
 }
 
 func handleInstall(args []string) {
-	if len(args) > 0 {
-		if err := resolver.AddNewPackage(args[0]); err != nil {
-			fmt.Printf("Error adding package %s: %v\n", args[0], err)
+	positional, flags, err := splitArgs(args, installFlags, 1)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		printUsage()
+		os.Exit(1)
+	}
+	jobs, err := parseJobs(flags)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts := resolver.InstallOptions{
+		Frozen:     hasFlag(flags, "frozen") || hasFlag(flags, "frozen-lockfile"),
+		Jobs:       jobs,
+		StrictTags: hasFlag(flags, "strict-tags"),
+		Offline:    isOffline(flags),
+		Name:       flags["as"],
+	}
+	if hasFlag(flags, "save-exact") {
+		opts.Save = resolver.SaveExact
+	} else if hasFlag(flags, "save-tilde") {
+		opts.Save = resolver.SaveTilde
+	}
+	if len(positional) > 0 {
+		if opts.Frozen {
+			fmt.Println("Error: cannot add a package with --frozen.")
+			os.Exit(1)
+		}
+		if err := resolver.AddNewPackage(positional[0], opts); err != nil {
+			fmt.Printf("Error adding package %s: %v\n", positional[0], err)
 			os.Exit(1)
 		}
 	}
-	if err := resolver.InstallDependencies(false); err != nil {
+	if err := resolver.InstallDependencies(opts); err != nil {
 		fmt.Printf("Error installing dependencies: %v\n", err)
+		if errors.Is(err, resolver.ErrLockfileOutOfDate) {
+			os.Exit(exitLockfileOutOfDate)
+		}
 		os.Exit(1)
 	}
 }
 
-func handleUpgrade() {
+func handleUpgrade(args []string) {
+	_, flags, err := splitArgs(args, upgradeFlags, 0)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		printUsage()
+		os.Exit(1)
+	}
+	jobs, err := parseJobs(flags)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 	fmt.Println("Upgrading all packages to the latest versions satisfying cppkg.json...")
-	if err := resolver.InstallDependencies(true); err != nil {
+	opts := resolver.InstallOptions{
+		Upgrade:    true,
+		Jobs:       jobs,
+		StrictTags: hasFlag(flags, "strict-tags"),
+		Offline:    isOffline(flags),
+	}
+	if err := resolver.InstallDependencies(opts); err != nil {
 		fmt.Printf("Error upgrading dependencies: %v\n", err)
 		os.Exit(1)
 	}
 }
 
 func handleUninstall(args []string) {
-	if len(args) == 0 {
+	positional, flags, err := splitArgs(args, uninstallFlags, 1)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		printUsage()
+		os.Exit(1)
+	}
+	if len(positional) == 0 {
 		fmt.Println("Error: uninstall command requires a package name.")
 		printUsage()
 		os.Exit(1)
 	}
-	packageName := args[0]
-	if err := resolver.UninstallPackage(packageName); err != nil {
+	jobs, err := parseJobs(flags)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts := resolver.InstallOptions{
+		Jobs:       jobs,
+		StrictTags: hasFlag(flags, "strict-tags"),
+		Offline:    isOffline(flags),
+	}
+	packageName := positional[0]
+	if err := resolver.UninstallPackage(packageName, opts); err != nil {
 		fmt.Printf("Error uninstalling package %s: %v\n", packageName, err)
 		os.Exit(1)
 	}
 }
 
+func handleVerify(args []string) {
+	_, flags, err := splitArgs(args, verifyFlags, 0)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		printUsage()
+		os.Exit(1)
+	}
+	if err := resolver.Verify(hasFlag(flags, "strict-tags"), isOffline(flags)); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// flagSet lists the flags a command accepts. Flags mapped to true take a
+// value.
+type flagSet map[string]bool
+
+var (
+	installFlags = flagSet{
+		"frozen": false, "frozen-lockfile": false, "strict-tags": false, "offline": false,
+		"save-exact": false, "save-tilde": false, "jobs": true, "as": true,
+	}
+	upgradeFlags   = flagSet{"strict-tags": false, "offline": false, "jobs": true}
+	uninstallFlags = flagSet{"strict-tags": false, "offline": false, "jobs": true}
+	verifyFlags    = flagSet{"strict-tags": false, "offline": false}
+)
+
+// splitArgs separates positional arguments from --flags. Flags that take a
+// value consume the following argument (or an inline "=value"). Flags the
+// command does not know, and more than maxPositional other arguments, are
+// errors, so that a typo never silently changes what a command does.
+func splitArgs(args []string, known flagSet, maxPositional int) ([]string, map[string]string, error) {
+	positional := make([]string, 0, len(args))
+	flags := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		takesValue, ok := known[name]
+		if !ok || !strings.HasPrefix(arg, "--") {
+			return nil, nil, fmt.Errorf("unknown flag %s", arg)
+		}
+		if !takesValue && hasValue {
+			return nil, nil, fmt.Errorf("flag --%s does not take a value", name)
+		}
+		if takesValue && !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("flag --%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		flags[name] = value
+	}
+	if len(positional) > maxPositional {
+		return nil, nil, fmt.Errorf("unexpected argument %s", positional[maxPositional])
+	}
+	return positional, flags, nil
+}
+
+// hasFlag reports whether a flag was passed on the command line.
+func hasFlag(flags map[string]string, name string) bool {
+	_, ok := flags[name]
+	return ok
+}
+
+// isOffline reports whether --offline was passed or CPPKG_OFFLINE is set.
+func isOffline(flags map[string]string) bool {
+	if hasFlag(flags, "offline") {
+		return true
+	}
+	env := os.Getenv("CPPKG_OFFLINE")
+	return env != "" && env != "0" && env != "false"
+}
+
+// parseJobs reads the --jobs flag. Zero lets the resolver pick a default.
+func parseJobs(flags map[string]string) (int, error) {
+	value, ok := flags["jobs"]
+	if !ok {
+		return 0, nil
+	}
+	jobs, err := strconv.Atoi(value)
+	if err != nil || jobs < 1 {
+		return 0, fmt.Errorf("--jobs must be a positive number, got %q", value)
+	}
+	return jobs, nil
+}
+
 func printUsage() {
 	fmt.Println("Usage: cppkg <command> [arguments]")
 	fmt.Println("\nCommands:")
 	fmt.Println("  init          Initialize a new project (creates cppkg.json)")
 	fmt.Println("  install       Install all dependencies from cppkg.json")
 	fmt.Println("  install <url#version> Install a single new package and add to cppkg.json")
+	fmt.Println("  install <url>     Add the newest stable version, saved as ^x.y.z (or --save-tilde, --save-exact)")
+	fmt.Println("  install <url> --as <name>  Add a package under a name of your choice")
+	fmt.Println("  install --frozen  Install from cppkg.lock, failing if it is out of date")
 	fmt.Println("  upgrade       Upgrade all packages to their latest allowed versions")
 	fmt.Println("  uninstall <name>  Remove a dependency from the project")
+	fmt.Println("  verify        Check installed and cached packages against cppkg.lock")
+	fmt.Println("\nOptions:")
+	fmt.Println("  --jobs <n>    Fetch and install at most n packages at once (default: CPU count)")
+	fmt.Println("  --strict-tags Fail instead of warning when a locked tag was moved upstream")
+	fmt.Println("  --offline     Never access the network (also set by CPPKG_OFFLINE=1)")
 }
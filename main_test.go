@@ -0,0 +1,68 @@
+// File: cpp-package-manager/main_test.go
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain lets tests run the cppkg command line in a child process, so that
+// they can check its exit code.
+func TestMain(m *testing.M) {
+	if args := os.Getenv("CPPKG_TEST_ARGS"); args != "" {
+		os.Args = append([]string{"cppkg"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCppkg runs cppkg with args in dir and returns its exit code.
+func runCppkg(t *testing.T, dir string, args ...string) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "CPPKG_TEST_ARGS="+strings.Join(args, "\n"), "XDG_CACHE_HOME="+t.TempDir())
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("running cppkg %v: %v\n%s", args, err, out)
+	}
+	return 0
+}
+
+func TestFrozenExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		lock string // "" leaves cppkg.lock out
+		want int
+	}{
+		{"missing lock", "", exitLockfileOutOfDate},
+		{"stale lock", `{"dependencies": {"old": {"url": "https://x/old", "version": "v1.0.0", "commit": "abc"}}}`, exitLockfileOutOfDate},
+		{"up to date", `{"dependencies": {}}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			manifest := `{"name": "p", "version": "0.1.0", "dependencies": {}}`
+			if err := os.WriteFile(filepath.Join(dir, "cppkg.json"), []byte(manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.lock != "" {
+				if err := os.WriteFile(filepath.Join(dir, "cppkg.lock"), []byte(tt.lock), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := runCppkg(t, dir, "install", "--frozen"); got != tt.want {
+				t.Errorf("cppkg install --frozen exited with %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
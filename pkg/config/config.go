@@ -3,7 +3,11 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"sort"
 
 	"cpp-package-manager/pkg/types"
 )
@@ -17,6 +21,9 @@ const (
 	ModulesDir = "cpp_modules"
 	// CacheDir is the directory where packages are cached.
 	CacheDir = ".cppkg_cache"
+	// InstallManifestName is the file inside ModulesDir recording what is
+	// installed there.
+	InstallManifestName = ".cppkg-install.json"
 )
 
 // ...types moved to pkg/types/types.go...
@@ -32,6 +39,11 @@ func LoadConfigFromPath(path string) (*types.PackageConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses the contents of a cppkg.json file.
+func ParseConfig(data []byte) (*types.PackageConfig, error) {
 	var cfg types.PackageConfig
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, err
@@ -40,7 +52,7 @@ func LoadConfigFromPath(path string) (*types.PackageConfig, error) {
 	if cfg.Dependencies == nil {
 		cfg.Dependencies = make(map[string]string)
 	}
-	return &cfg, err
+	return &cfg, nil
 }
 
 // SaveConfig writes the config data to cppkg.json
@@ -62,19 +74,107 @@ func LoadLockfile() (*types.LockFile, error) {
 		return nil, err
 	}
 	var lock types.LockFile
-	err = json.Unmarshal(data, &lock)
-	return &lock, err
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+	if lock.Version > types.LockfileVersion {
+		return nil, fmt.Errorf("%s has format version %d, but this cppkg only understands up to %d; please upgrade cppkg", LockFileName, lock.Version, types.LockfileVersion)
+	}
+	return &lock, nil
 }
 
 // SaveLockfile writes the lock data to cppkg.lock
 func SaveLockfile(lock *types.LockFile) error {
-	data, err := json.MarshalIndent(lock, "", "  ")
+	data, err := EncodeLockfile(lock)
 	if err != nil {
 		return err
 	}
 	return os.WriteFile(LockFileName, data, 0644)
 }
 
+// EncodeLockfile returns the contents cppkg.lock would have for lock, in the
+// current format version.
+func EncodeLockfile(lock *types.LockFile) ([]byte, error) {
+	current := *lock
+	current.Version = types.LockfileVersion
+	return json.MarshalIndent(&current, "", "  ")
+}
+
+// LoadInstallManifest reads the install manifest of a modules directory. A
+// directory without one is treated as having nothing installed.
+func LoadInstallManifest(modulesDir string) (*types.InstallManifest, error) {
+	manifest := &types.InstallManifest{Packages: make(map[string]types.InstalledPackage)}
+	data, err := os.ReadFile(filepath.Join(modulesDir, InstallManifestName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Packages == nil {
+		manifest.Packages = make(map[string]types.InstalledPackage)
+	}
+	return manifest, nil
+}
+
+// SaveInstallManifest writes the install manifest of a modules directory.
+func SaveInstallManifest(modulesDir string, manifest *types.InstallManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(modulesDir, InstallManifestName), data, 0644)
+}
+
+// DiffLockfiles describes every difference between an existing lock and a
+// freshly resolved one, one line per change, sorted by package name.
+func DiffLockfiles(old, new *types.LockFile) []string {
+	names := make(map[string]bool)
+	for name := range old.Dependencies {
+		names[name] = true
+	}
+	for name := range new.Dependencies {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var diff []string
+	for _, name := range sorted {
+		o, inOld := old.Dependencies[name]
+		n, inNew := new.Dependencies[name]
+		switch {
+		case !inOld:
+			diff = append(diff, fmt.Sprintf("+ %s @ %s (missing from %s)", name, n.Version, LockFileName))
+		case !inNew:
+			diff = append(diff, fmt.Sprintf("- %s @ %s (no longer required)", name, o.Version))
+		case o.URL != n.URL:
+			diff = append(diff, fmt.Sprintf("~ %s: url %s -> %s", name, o.URL, n.URL))
+		case o.Version != n.Version:
+			diff = append(diff, fmt.Sprintf("~ %s: version %s -> %s", name, o.Version, n.Version))
+		case o.Commit != n.Commit:
+			diff = append(diff, fmt.Sprintf("~ %s: commit %s -> %s", name, o.Commit, n.Commit))
+		case o.Checksum != n.Checksum:
+			diff = append(diff, fmt.Sprintf("~ %s: checksum %s -> %s", name, o.Checksum, n.Checksum))
+		// Locks from before the graph was recorded only differ in format.
+		case old.Version < types.LockfileGraphVersion:
+		case o.Root && !n.Root:
+			diff = append(diff, fmt.Sprintf("~ %s: no longer a direct dependency", name))
+		case !o.Root && n.Root:
+			diff = append(diff, fmt.Sprintf("~ %s: now a direct dependency", name))
+		case !maps.Equal(o.Dependencies, n.Dependencies):
+			diff = append(diff, fmt.Sprintf("~ %s: dependencies changed", name))
+		}
+	}
+	return diff
+}
+
 // GetModulesDir returns the path to the dependency installation directory.
 func GetModulesDir() string {
 	return ModulesDir
@@ -84,3 +184,19 @@ func GetModulesDir() string {
 func GetCacheDir() string {
 	return CacheDir
 }
+
+// GetMirrorDir returns the user-level directory holding bare mirrors of every
+// repository cppkg has fetched. It honors $XDG_CACHE_HOME.
+func GetMirrorDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "cppkg", "git")
+}
+
+// GetArchiveDir returns the user-level directory holding the unpacked
+// contents of every release archive cppkg has downloaded.
+func GetArchiveDir() string {
+	return filepath.Join(filepath.Dir(GetMirrorDir()), "archives")
+}
@@ -0,0 +1,61 @@
+// File: cpp-package-manager/pkg/config/config_test.go
+package config
+
+import (
+	"cpp-package-manager/pkg/types"
+	"maps"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestDiffLockfiles(t *testing.T) {
+	a := types.LockedDependency{URL: "https://x/a", Version: "v1.0.0", Commit: "aaa", Root: true, Dependencies: map[string]string{"b": "^1.0.0"}}
+	b := types.LockedDependency{URL: "https://x/b", Version: "v1.0.0", Commit: "bbb"}
+	// with returns a copy of d changed by change.
+	with := func(d types.LockedDependency, change func(*types.LockedDependency)) types.LockedDependency {
+		d.Dependencies = maps.Clone(d.Dependencies)
+		change(&d)
+		return d
+	}
+	// lock names each package after the last element of its URL.
+	lock := func(version int, deps ...types.LockedDependency) *types.LockFile {
+		l := &types.LockFile{Version: version, Dependencies: map[string]types.LockedDependency{}}
+		for _, d := range deps {
+			l.Dependencies[path.Base(d.URL)] = d
+		}
+		return l
+	}
+	tests := []struct {
+		name     string
+		old, new *types.LockFile
+		want     []string
+	}{
+		{"identical", lock(2, a, b), lock(2, a, b), nil},
+		{"added", lock(2, a), lock(2, a, b), []string{"+ b @ v1.0.0 (missing from cppkg.lock)"}},
+		{"removed", lock(2, a, b), lock(2, a), []string{"- b @ v1.0.0 (no longer required)"}},
+		{"url", lock(2, a), lock(2, with(a, func(d *types.LockedDependency) { d.URL = "https://fork/a" })),
+			[]string{"~ a: url https://x/a -> https://fork/a"}},
+		{"version", lock(2, a), lock(2, with(a, func(d *types.LockedDependency) { d.Version, d.Commit = "v1.1.0", "ccc" })),
+			[]string{"~ a: version v1.0.0 -> v1.1.0"}},
+		{"commit", lock(2, a), lock(2, with(a, func(d *types.LockedDependency) { d.Commit = "ccc" })),
+			[]string{"~ a: commit aaa -> ccc"}},
+		{"checksum", lock(2, with(a, func(d *types.LockedDependency) { d.Commit, d.Checksum = "", "sha256=1" })),
+			lock(2, with(a, func(d *types.LockedDependency) { d.Commit, d.Checksum = "", "sha256=2" })),
+			[]string{"~ a: checksum sha256=1 -> sha256=2"}},
+		{"no longer root", lock(2, a), lock(2, with(a, func(d *types.LockedDependency) { d.Root = false })),
+			[]string{"~ a: no longer a direct dependency"}},
+		{"dependencies", lock(2, a), lock(2, with(a, func(d *types.LockedDependency) { d.Dependencies["b"] = "^2.0.0" })),
+			[]string{"~ a: dependencies changed"}},
+		{"integrity is not a difference", lock(2, a), lock(2, with(a, func(d *types.LockedDependency) { d.Integrity = "sha256-x" })), nil},
+		{"graph of an old lock is not compared",
+			lock(1, with(a, func(d *types.LockedDependency) { d.Root, d.Dependencies = false, nil })), lock(2, a), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffLockfiles(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffLockfiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
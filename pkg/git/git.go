@@ -2,6 +2,9 @@
 package git
 
 import (
+	"bytes"
+	"cpp-package-manager/pkg/utils"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,13 +13,41 @@ import (
 	"strings"
 )
 
-// runGitCommand executes a git command. If progress is not nil, it streams stderr.
-// Otherwise, it returns the combined output.
-func runGitCommand(dir string, progress io.Writer, args ...string) (string, error) {
+// ErrOffline is returned by operations that would contact a remote while
+// offline mode is on.
+var ErrOffline = errors.New("not available offline")
+
+// offline makes every operation that needs the network fail with ErrOffline.
+var offline bool
+
+// SetOffline turns offline mode on or off. While it is on, git is also
+// forbidden from using any transport, so that partial clones cannot lazily
+// fetch missing objects behind our back.
+func SetOffline(on bool) {
+	offline = on
+}
+
+// IsOffline reports whether offline mode is on.
+func IsOffline() bool {
+	return offline
+}
+
+// gitCommand prepares a git command, honoring offline mode.
+func gitCommand(dir string, args ...string) *exec.Cmd {
 	cmd := exec.Command("git", args...)
 	if dir != "" {
 		cmd.Dir = dir
 	}
+	if offline {
+		cmd.Env = append(os.Environ(), "GIT_ALLOW_PROTOCOL=none")
+	}
+	return cmd
+}
+
+// runGitCommand executes a git command. If progress is not nil, it streams stderr.
+// Otherwise, it returns the combined output.
+func runGitCommand(dir string, progress io.Writer, args ...string) (string, error) {
+	cmd := gitCommand(dir, args...)
 
 	// If a progress writer is provided, stream stderr to it.
 	if progress != nil {
@@ -39,11 +70,146 @@ func runGitCommand(dir string, progress io.Writer, args ...string) (string, erro
 
 // Clone clones a repository from a URL to a destination path, showing progress.
 func Clone(url, dest string, progress io.Writer) error {
-	// Add --progress flag to ensure git prints progress information.
-	_, err := runGitCommand("", progress, "clone", "--progress", url, dest)
+	if offline {
+		return fmt.Errorf("%w: cannot clone %s", ErrOffline, url)
+	}
+	// Add --progress flag to ensure git prints progress information. The "--"
+	// keeps a URL starting with a dash from being read as an option.
+	_, err := runGitCommand("", progress, "clone", "--progress", "--", url, dest)
 	return err
 }
 
+// Mirror keeps a bare mirror of a repository at path up to date. The first
+// call clones it; later calls only fetch what changed upstream. Mirrors are
+// blobless partial clones: commits and trees are fetched eagerly, while file
+// contents are only downloaded once something reads them.
+func Mirror(url, path string, progress io.Writer) error {
+	if _, err := os.Stat(path); err == nil {
+		if offline {
+			return nil
+		}
+		// Mirrors are shared by every spelling of a repository's URL, so it
+		// may have been cloned through another one. Fetch, and fill in
+		// missing blobs later, through the one in use now.
+		if _, err := runGitCommand(path, nil, "remote", "set-url", "origin", url); err != nil {
+			return err
+		}
+		_, err := runGitCommand(path, progress, "fetch", "--progress", "--prune", "origin")
+		return err
+	}
+	if offline {
+		return fmt.Errorf("%w: %s has never been downloaded", ErrOffline, url)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Clone into a temporary sibling so that an interrupted clone never
+	// leaves a half-populated mirror behind. Mirrors are shared by every
+	// project, so each run clones into a directory of its own.
+	tempPath, err := os.MkdirTemp(filepath.Dir(path), filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempPath)
+	if _, err := runGitCommand("", progress, "clone", "--mirror", "--filter=blob:none", "--progress", "--", url, tempPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		// Another run finished cloning the same repository first.
+		if _, statErr := os.Stat(path); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// HasCommit reports whether a repository already contains a commit. It never
+// fetches, even from a partial clone's promisor remote.
+func HasCommit(repoPath, commit string) bool {
+	if _, err := os.Stat(repoPath); err != nil {
+		return false
+	}
+	_, err := runGitCommand(repoPath, nil, "rev-list", "-n1", "--missing=print", commit, "--")
+	return err == nil
+}
+
+// ReadFile returns the contents of a file at a given ref, or nil if the file
+// does not exist at that ref.
+func ReadFile(repoPath, ref, name string) ([]byte, error) {
+	listing, err := runGitCommand(repoPath, nil, "ls-tree", ref, "--", name)
+	if err != nil {
+		return nil, err
+	}
+	if listing == "" {
+		return nil, nil
+	}
+	cmd := gitCommand(repoPath, "cat-file", "blob", ref+":"+name)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	data, err := cmd.Output()
+	if err != nil {
+		if offline {
+			return nil, fmt.Errorf("%w: %s at %s was never downloaded", ErrOffline, name, ref)
+		}
+		return nil, fmt.Errorf("git error: %s\n%s", err, stderr.String())
+	}
+	return data, nil
+}
+
+// Archive extracts the tree of a commit into dest using 'git archive', which
+// works on bare repositories and never includes git metadata.
+func Archive(repoPath, commit, dest string) error {
+	if err := prefetchBlobs(repoPath, commit); err != nil {
+		return fmt.Errorf("could not fetch contents of %s: %w", commit, err)
+	}
+	cmd := gitCommand(repoPath, "archive", "--format=tar", commit)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	extractErr := utils.ExtractTar(stdout, dest)
+	// Drain the pipe so that git can exit even if extraction stopped early.
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git error: %s\n%s", err, stderr.String())
+	}
+	return extractErr
+}
+
+// prefetchBlobs downloads, in a single batch, every blob of a commit that a
+// blobless mirror does not have yet. Without this, git would lazily fetch
+// them one at a time.
+func prefetchBlobs(repoPath, commit string) error {
+	output, err := runGitCommand(repoPath, nil, "rev-list", "--objects", "--missing=print", commit)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "?") {
+			missing = append(missing, strings.TrimPrefix(line, "?"))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if offline {
+		return fmt.Errorf("%w: %d files of commit %s were never downloaded", ErrOffline, len(missing), commit)
+	}
+	cmd := gitCommand(repoPath, "fetch", "--no-tags", "--no-write-fetch-head", "--filter=blob:none", "--stdin", "origin")
+	cmd.Stdin = strings.NewReader(strings.Join(missing, "\n") + "\n")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git error: %s\n%s", err, output)
+	}
+	return nil
+}
+
 // Checkout switches the repository at a given path to a specific tag or commit.
 func Checkout(repoPath, ref string) error {
 	_, err := runGitCommand(repoPath, nil, "checkout", ref)
@@ -62,18 +228,85 @@ func ListTags(repoPath string) ([]string, error) {
 	return strings.Split(output, "\n"), nil
 }
 
-// GetCommitHash resolves a tag/branch to its full commit SHA.
-func GetCommitHash(repoPath, ref string) (string, error) {
-	// Fetch latest tags from remote before resolving
-	_, err := runGitCommand(repoPath, nil, "fetch", "--all", "--tags")
+// ListTagCommits maps every tag in a repository to the commit it points at,
+// peeling annotated tags.
+func ListTagCommits(repoPath string) (map[string]string, error) {
+	output, err := runGitCommand(repoPath, nil, "for-each-ref", "--format=%(refname:short) %(objectname) %(*objectname)", "refs/tags")
 	if err != nil {
-		return "", fmt.Errorf("could not fetch tags: %w", err)
+		return nil, err
 	}
-	// Using "tags/" prefix is a robust way to reference a tag
-	return runGitCommand(repoPath, nil, "rev-parse", "tags/"+ref)
+	commits := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// The third field is only present for annotated tags.
+		commits[fields[0]] = fields[len(fields)-1]
+	}
+	return commits, nil
 }
 
-// CopyDir recursively copies a directory from src to dst.
+// ListRemoteTags maps every tag of a remote repository to the commit it
+// points at, without cloning it. Annotated tags are reported by ls-remote
+// twice; the peeled "^{}" entry names the commit and takes precedence.
+func ListRemoteTags(url string) (map[string]string, error) {
+	if offline {
+		return nil, fmt.Errorf("%w: cannot list tags of %s", ErrOffline, url)
+	}
+	output, err := runGitCommand("", nil, "ls-remote", "--tags", "--", url)
+	if err != nil {
+		return nil, err
+	}
+	commits := make(map[string]string)
+	peeled := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		sha, ref, ok := strings.Cut(line, "\t")
+		if !ok || !strings.HasPrefix(ref, "refs/tags/") {
+			continue
+		}
+		tag := strings.TrimPrefix(ref, "refs/tags/")
+		if strings.HasSuffix(tag, "^{}") {
+			tag = strings.TrimSuffix(tag, "^{}")
+			commits[tag] = sha
+			peeled[tag] = true
+		} else if !peeled[tag] {
+			commits[tag] = sha
+		}
+	}
+	return commits, nil
+}
+
+// GetCommitHash resolves a tag, or failing that a commit or branch, to its
+// full commit SHA. The mirror is expected to be up to date already.
+func GetCommitHash(repoPath, ref string) (string, error) {
+	if commit, err := RevParse(repoPath, "refs/tags/"+ref); err == nil {
+		return commit, nil
+	}
+	return RevParse(repoPath, ref)
+}
+
+// RevParse resolves a revision to the full SHA of the commit it names.
+func RevParse(repoPath, rev string) (string, error) {
+	commit, err := runGitCommand(repoPath, nil, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil || commit == "" {
+		return "", fmt.Errorf("%s does not name a commit", rev)
+	}
+	return commit, nil
+}
+
+// FetchCommit fetches a single commit that no branch or tag of the mirror
+// reaches, such as one on a deleted branch.
+func FetchCommit(repoPath, commit string) error {
+	if offline {
+		return fmt.Errorf("%w: commit %s was never downloaded", ErrOffline, commit)
+	}
+	_, err := runGitCommand(repoPath, nil, "fetch", "--no-tags", "--no-write-fetch-head", "--filter=blob:none", "origin", commit)
+	return err
+}
+
+// CopyDir recursively copies a directory from src to dst. Symlinks are
+// recreated as symlinks, so that the copy hashes like the original.
 func CopyDir(src, dst string) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -87,6 +320,13 @@ func CopyDir(src, dst string) error {
 		if info.IsDir() {
 			return os.MkdirAll(dstPath, info.Mode())
 		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dstPath)
+		}
 		srcFile, err := os.Open(path)
 		if err != nil {
 			return err
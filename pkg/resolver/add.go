@@ -0,0 +1,155 @@
+// File: cpp-package-manager/pkg/resolver/add.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/config"
+	"cpp-package-manager/pkg/git"
+	"cpp-package-manager/pkg/source"
+	"cpp-package-manager/pkg/spec"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// AddNewPackage handles 'install <url#version>'. Without a version, the
+// newest stable tag is looked up and saved as opts.Save says. The package is
+// saved under opts.Name if given, else under the name it declares in its
+// own cppkg.json, else under a name derived from its URL.
+func AddNewPackage(pkgStr string, opts InstallOptions) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("could not load cppkg.json, did you run 'cppkg init'?: %w", err)
+	}
+	sp, err := spec.Parse(pkgStr)
+	if err != nil {
+		return err
+	}
+
+	git.SetOffline(opts.Offline)
+	sess := newSession(opts.Jobs)
+	reg := newRegistry(sess)
+	if sp.Ref == "" && !source.IsPath(sp.URL) {
+		if sp.Ref, err = latestStable(reg, sp.URL, opts.Save); err != nil {
+			return err
+		}
+	}
+	declared, err := declaredName(reg, sp)
+	if err != nil {
+		if report := sess.missingReport(); report != "" {
+			return errors.New(report)
+		}
+		return err
+	}
+
+	name := source.PackageName(sp.URL)
+	switch {
+	case opts.Name != "":
+		name = opts.Name
+	case declared != "":
+		name = declared
+	}
+	if err := checkName(name, sp.URL, cfg.Dependencies); err != nil {
+		return err
+	}
+	if declared != "" && declared != name {
+		fmt.Printf("  ! WARNING: %s calls itself '%s' in its %s, but is saved as '%s'\n", sp.URL, declared, config.ConfigFile, name)
+	}
+
+	if cfg.Dependencies == nil {
+		cfg.Dependencies = make(map[string]string)
+	}
+	cfg.Dependencies[name] = sp.String()
+	fmt.Printf("  - Saving %s as %s\n", name, sp.String())
+	return config.SaveConfig(cfg)
+}
+
+// checkName makes sure a package can be saved under name without replacing
+// a different package, whether a direct dependency in cppkg.json or an
+// indirect one in cppkg.lock. Re-adding the same repository, however its
+// URL is spelled, only changes its version.
+func checkName(name, url string, deps map[string]string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	if pkgStr, ok := deps[name]; ok {
+		if existing, err := spec.Parse(pkgStr); err != nil || source.Identity(existing.URL) != source.Identity(url) {
+			return fmt.Errorf("%s already has a package named '%s' (%s); use --as <name> to add this one under another name", config.ConfigFile, name, pkgStr)
+		}
+		return nil
+	}
+	lock, err := config.LoadLockfile()
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", config.LockFileName, err)
+	}
+	if dep, ok := lock.Dependencies[name]; ok && source.Identity(dep.URL) != source.Identity(url) {
+		return fmt.Errorf("'%s' is already the name of an indirect dependency (%s); use --as <name> to add this one under another name", name, dep.URL)
+	}
+	return nil
+}
+
+// declaredName returns the name a package gives itself in its own
+// cppkg.json, at the version the spec selects. It returns "" if the package
+// has no manifest or no name, or if no version matches, which the install
+// that follows will report properly.
+func declaredName(reg *registry, sp spec.Spec) (string, error) {
+	s := &solver{reg: reg}
+	reqs := []requirement{{from: "root", url: sp.URL, constraint: sp.Constraint()}}
+	candidates, err := s.candidates(sp.URL, reqs, io.Discard)
+	if err != nil || len(candidates) == 0 {
+		return "", err
+	}
+	src, err := reg.sess.source(sp.URL)
+	if err != nil {
+		return "", err
+	}
+	data, err := src.Manifest(candidates[0].revision(), io.Discard)
+	if err != nil || data == nil {
+		return "", reg.sess.noteMissing(err)
+	}
+	cfg, err := config.ParseConfig(data)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s of %s: %w", config.ConfigFile, sp.URL, err)
+	}
+	return cfg.Name, nil
+}
+
+// SaveMode controls how 'install <url>' records the version it picked.
+type SaveMode int
+
+const (
+	// SaveCaret saves "^1.2.3", accepting any compatible release.
+	SaveCaret SaveMode = iota
+	// SaveTilde saves "~1.2.3", accepting patch releases only.
+	SaveTilde
+	// SaveExact saves "1.2.3".
+	SaveExact
+)
+
+// latestStable returns a constraint for the newest tag of url that is not
+// a prerelease, formatted according to save.
+func latestStable(reg *registry, url string, save SaveMode) (string, error) {
+	versions, err := reg.versions(url)
+	if err != nil {
+		if report := reg.sess.missingReport(); report != "" {
+			return "", errors.New(report)
+		}
+		return "", fmt.Errorf("could not list versions of %s: %w", url, err)
+	}
+	for _, c := range versions {
+		v, err := semver.NewVersion(c.version)
+		if err != nil || v.Prerelease() != "" {
+			continue
+		}
+		switch save {
+		case SaveTilde:
+			return "~" + v.String(), nil
+		case SaveExact:
+			return v.String(), nil
+		default:
+			return "^" + v.String(), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no stable semver tags; give a version explicitly, e.g. '%s#branch=main'", url, url)
+}
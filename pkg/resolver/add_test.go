@@ -0,0 +1,68 @@
+// File: cpp-package-manager/pkg/resolver/add_test.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/config"
+	"cpp-package-manager/pkg/types"
+	"testing"
+)
+
+func TestLatestStable(t *testing.T) {
+	tags := map[string]map[string]string{"v1.2.0": nil, "v1.10.1": nil, "v2.0.0-rc.1": nil, "nightly": nil}
+	tests := []struct {
+		save SaveMode
+		want string
+	}{
+		{SaveCaret, "^1.10.1"},
+		{SaveTilde, "~1.10.1"},
+		{SaveExact, "1.10.1"},
+	}
+	for _, tt := range tests {
+		sess := &session{jobs: 1, open: fakeOpener(map[string]map[string]map[string]string{"https://x/a": tags})}
+		got, err := latestStable(newRegistry(sess), "https://x/a", tt.save)
+		if err != nil {
+			t.Fatalf("latestStable(%v): %v", tt.save, err)
+		}
+		if got != tt.want {
+			t.Errorf("latestStable(%v) = %s, want %s", tt.save, got, tt.want)
+		}
+	}
+
+	sess := &session{jobs: 1, open: fakeOpener(map[string]map[string]map[string]string{"https://x/b": {"v1.0.0-beta": nil}})}
+	if got, err := latestStable(newRegistry(sess), "https://x/b", SaveCaret); err == nil {
+		t.Errorf("latestStable of a package with only prereleases = %s, want an error", got)
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	inProject(t, `{"name": "app", "dependencies": {}}`)
+	lock := &types.LockFile{Dependencies: map[string]types.LockedDependency{
+		"zlib": {URL: "https://x/zlib"},
+	}}
+	if err := config.SaveLockfile(lock); err != nil {
+		t.Fatal(err)
+	}
+	deps := map[string]string{"fmt": "https://x/fmt#^10.0.0"}
+
+	tests := []struct {
+		name, url string
+		ok        bool
+	}{
+		{"fmt", "https://x/fmt", true},
+		{"fmt", "https://y/fmt", false},
+		{"zlib", "https://x/zlib", true},
+		{"zlib", "https://y/zlib", false},
+		{"spdlog", "https://x/spdlog", true},
+		{"", "https://x/a", false},
+		{"..", "https://x/a", false},
+		{"a/b", "https://x/a", false},
+		{"a#b", "https://x/a", false},
+		{"a@b", "https://x/a", false},
+	}
+	for _, tt := range tests {
+		err := checkName(tt.name, tt.url, deps)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkName(%q, %s) = %v, want ok=%v", tt.name, tt.url, err, tt.ok)
+		}
+	}
+}
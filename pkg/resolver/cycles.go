@@ -0,0 +1,82 @@
+// File: cpp-package-manager/pkg/resolver/cycles.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/config"
+	"fmt"
+	"strings"
+)
+
+// CycleError is returned when the selected packages depend on each other in
+// a cycle and the root manifest does not allow it.
+type CycleError struct {
+	// Path lists the packages of the cycle in order, ending with the first.
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s; set \"allowCycles\": true in %s to permit it",
+		strings.Join(e.Path, " -> "), config.ConfigFile)
+}
+
+// findCycle returns the first dependency cycle among the decided packages,
+// as "name@version" entries ending with the one it started from, or nil if
+// the graph is acyclic. Packages are visited in sorted order, so the same
+// graph always yields the same cycle.
+func (s *solver) findCycle(reqs map[string][]requirement) []string {
+	edges := make(map[string][]string)
+	for _, name := range sortedKeys(reqs) {
+		for _, req := range reqs[name] {
+			if req.parent != "" {
+				edges[req.parent] = append(edges[req.parent], name)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, next := range edges[name] {
+			switch state[next] {
+			case visiting:
+				for i, n := range stack {
+					if n == next {
+						return s.labels(append(append([]string(nil), stack[i:]...), next))
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		return nil
+	}
+	for _, name := range sortedKeys(s.decisions) {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// labels renders package names as "name@version" using their decisions.
+func (s *solver) labels(names []string) []string {
+	labels := make([]string, len(names))
+	for i, name := range names {
+		labels[i] = fmt.Sprintf("%s@%s", name, s.decisions[name].version)
+	}
+	return labels
+}
@@ -0,0 +1,107 @@
+// File: cpp-package-manager/pkg/resolver/explain.go
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// ResolutionError is returned when no combination of versions satisfies the
+// dependency graph. Each conflict names every requirer of the offending
+// package along with the chain that led to it.
+type ResolutionError struct {
+	Conflicts []string
+}
+
+func (e *ResolutionError) Error() string {
+	if len(e.Conflicts) == 0 {
+		return "no combination of versions satisfies every constraint"
+	}
+	return "no combination of versions satisfies every constraint:\n" + strings.Join(e.Conflicts, "\n")
+}
+
+// recordConflict describes a dead end of the search: either the decided
+// version of a package violates a requirement, or no tag satisfies all of
+// them.
+func (s *solver) recordConflict(name, selected string, reqs map[string][]requirement, considered []string) {
+	var b strings.Builder
+	if selected != "" {
+		fmt.Fprintf(&b, "  %s @ %s was selected, but not every requirer accepts it:\n", name, selected)
+	} else {
+		fmt.Fprintf(&b, "  no version of %s satisfies every requirer:\n", name)
+	}
+	for _, req := range reqs[name] {
+		fmt.Fprintf(&b, "    %s requires %s %s\n", requirerChain(req, reqs), name, req.constraint)
+	}
+	if selected == "" {
+		if len(considered) == 0 {
+			fmt.Fprintf(&b, "    no semver tags were found in %s", reqs[name][0].url)
+		} else {
+			fmt.Fprintf(&b, "    tags considered: %s", strings.Join(considered, ", "))
+		}
+	}
+	s.addConflict(strings.TrimRight(b.String(), "\n"))
+}
+
+// addConflict keeps a conflict report unless an identical one was already
+// recorded by another branch of the search.
+func (s *solver) addConflict(report string) {
+	for _, c := range s.conflicts {
+		if c == report {
+			return
+		}
+	}
+	s.conflicts = append(s.conflicts, report)
+}
+
+// recordSourceConflict describes a package name that requirers map to
+// different sources, such as two forks of the same library.
+func (s *solver) recordSourceConflict(name string, reqs map[string][]requirement) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %s refers to different sources:\n", name)
+	for _, req := range reqs[name] {
+		fmt.Fprintf(&b, "    %s requires %s from %s\n", requirerChain(req, reqs), name, req.url)
+	}
+	s.addConflict(strings.TrimRight(b.String(), "\n"))
+}
+
+// considered lists the tags that were checked against a package's
+// requirements. Packages pinned to a tag or commit only have that one ref.
+func (s *solver) considered(reqs []requirement) ([]string, error) {
+	var pinned []string
+	for _, req := range reqs {
+		if _, err := semver.NewConstraint(req.constraint); err != nil && req.constraint != "" {
+			pinned = append(pinned, req.constraint)
+		}
+	}
+	if len(pinned) > 0 {
+		return pinned, nil
+	}
+	versions, err := s.reg.versions(reqs[0].url)
+	if err != nil {
+		return nil, err
+	}
+	considered := make([]string, len(versions))
+	for i, c := range versions {
+		considered[i] = c.version
+	}
+	return considered, nil
+}
+
+// requirerChain renders the path from the root manifest to the package that
+// placed a requirement, e.g. "root -> liba@v1.2.0".
+func requirerChain(req requirement, reqs map[string][]requirement) string {
+	chain := []string{req.from}
+	seen := map[string]bool{req.from: true}
+	for req.parent != "" && len(reqs[req.parent]) > 0 {
+		req = reqs[req.parent][0]
+		if seen[req.from] {
+			break
+		}
+		seen[req.from] = true
+		chain = append([]string{req.from}, chain...)
+	}
+	return strings.Join(chain, " -> ")
+}
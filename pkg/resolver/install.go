@@ -2,39 +2,27 @@
 package resolver
 
 import (
+	"bytes"
 	"cpp-package-manager/pkg/config"
 	"cpp-package-manager/pkg/git"
+	"cpp-package-manager/pkg/source"
+	"cpp-package-manager/pkg/spec"
 	"cpp-package-manager/pkg/types"
+	"cpp-package-manager/pkg/utils"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
-
-	"github.com/Masterminds/semver/v3"
+	"sync"
 )
 
-// AddNewPackage handles 'install <url#version>'
-func AddNewPackage(pkgStr string) error {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		return fmt.Errorf("could not load cppkg.json, did you run 'cppkg init'?: %w", err)
-	}
-	parts := strings.Split(pkgStr, "#")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid package format. Use 'url#version', e.g., 'https://github.com/user/repo.git#^1.0.0'")
-	}
-	url, version := parts[0], parts[1]
-	name := strings.TrimSuffix(filepath.Base(url), ".git")
-	if cfg.Dependencies == nil {
-		cfg.Dependencies = make(map[string]string)
-	}
-	cfg.Dependencies[name] = fmt.Sprintf("%s#%s", url, version)
-	return config.SaveConfig(cfg)
-}
-
-// UninstallPackage removes a dependency and re-resolves the tree.
-func UninstallPackage(name string) error {
+// UninstallPackage removes a dependency and re-resolves the tree, installing
+// the result with opts.
+func UninstallPackage(name string, opts InstallOptions) error {
 	fmt.Printf("Uninstalling %s...\n", name)
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -51,53 +39,86 @@ func UninstallPackage(name string) error {
 	}
 
 	fmt.Println("Re-resolving dependencies after uninstall...")
-	return InstallDependencies(false)
+	return InstallDependencies(opts)
+}
+
+// ErrLockfileOutOfDate is returned by a frozen install when cppkg.json and
+// cppkg.lock disagree.
+var ErrLockfileOutOfDate = errors.New("cppkg.lock is out of date with cppkg.json")
+
+// InstallOptions controls how InstallDependencies resolves packages.
+type InstallOptions struct {
+	// Upgrade ignores cppkg.lock and resolves every range from scratch.
+	Upgrade bool
+	// Frozen fails instead of modifying cppkg.lock when it is out of date.
+	Frozen bool
+	// Jobs bounds how many packages are fetched or installed at once.
+	// Zero means one per CPU.
+	Jobs int
+	// StrictTags fails when a locked tag has been re-pointed upstream,
+	// instead of only warning about it.
+	StrictTags bool
+	// Offline forbids all network access; everything must come from the
+	// mirrors, the project cache and cppkg.lock.
+	Offline bool
+	// Save controls how AddNewPackage records a version it looked up.
+	Save SaveMode
+	// Name overrides the name AddNewPackage saves a package under.
+	Name string
 }
 
 // InstallDependencies is the new entry point for installation.
-func InstallDependencies(isUpgrade bool) error {
-	if err := os.RemoveAll(config.GetModulesDir()); err != nil {
-		return fmt.Errorf("failed to clean modules directory: %w", err)
+func InstallDependencies(opts InstallOptions) error {
+	if opts.Frozen {
+		if _, err := os.Stat(config.LockFileName); err != nil {
+			return fmt.Errorf("%w: %s does not exist", ErrLockfileOutOfDate, config.LockFileName)
+		}
 	}
 
-	if isUpgrade {
+	if opts.Upgrade {
 		fmt.Println("Checking for new package versions...")
 	} else {
 		fmt.Println("Resolving dependency graph...")
 	}
 
-	discovered, err := discoverAllDependencies()
+	lock, err := config.LoadLockfile()
 	if err != nil {
-		return fmt.Errorf("failed during dependency discovery: %w", err)
+		return fmt.Errorf("could not read %s: %w", config.LockFileName, err)
+	}
+	if lock.Dependencies == nil {
+		lock.Dependencies = make(map[string]types.LockedDependency)
 	}
 
-	finalDeps, err := resolveConflicts(discovered)
+	if opts.Jobs < 1 {
+		opts.Jobs = runtime.NumCPU()
+	}
+	git.SetOffline(opts.Offline)
+	sess := newSession(opts.Jobs)
+	if opts.Offline {
+		sess.checkCached(knownURLs(lock))
+	}
+	finalDeps, err := resolveGraph(sess, opts, lock)
 	if err != nil {
+		if report := sess.missingReport(); report != "" {
+			return errors.New(report)
+		}
 		return fmt.Errorf("failed during version resolution: %w", err)
 	}
 
-	if err := os.MkdirAll(config.GetModulesDir(), 0755); err != nil {
-		return fmt.Errorf("failed to create modules directory: %w", err)
-	}
-
-	fmt.Println("Installing packages...")
-	newLockFile := &types.LockFile{Dependencies: make(map[string]types.LockedDependency)}
-	for name, dep := range finalDeps {
-		fmt.Printf("  - Installing %s @ %s\n", name, dep.Version)
-		if err := installPackage(name, dep.URL, dep.Commit); err != nil {
-			return fmt.Errorf("failed to install package %s: %w", name, err)
+	newLockFile := &types.LockFile{Dependencies: finalDeps}
+	if opts.Frozen {
+		if diff := config.DiffLockfiles(lock, newLockFile); len(diff) > 0 {
+			return fmt.Errorf("%w:\n  %s", ErrLockfileOutOfDate, strings.Join(diff, "\n  "))
 		}
-		newLockFile.Dependencies[name] = dep
 	}
 
-	if err := config.SaveLockfile(newLockFile); err != nil {
+	if err := applyInstall(sess, opts.Jobs, newLockFile, opts.Frozen); err != nil {
+		if report := sess.missingReport(); report != "" {
+			return errors.New(report)
+		}
 		return err
 	}
 
-	if err := generateCMakeFile(newLockFile); err != nil {
-		return fmt.Errorf("failed to generate cmake file: %w", err)
-	}
-
 	rootCfg, err := config.LoadConfig()
 	if err != nil {
 		return err
@@ -109,214 +130,225 @@ func InstallDependencies(isUpgrade bool) error {
 	return nil
 }
 
-type discoveryResult struct {
-	urls        map[string]string
-	constraints map[string][]string
+// knownURLs lists the sources of the root dependencies and of every locked
+// package.
+func knownURLs(lock *types.LockFile) []string {
+	seen := make(map[string]bool)
+	if cfg, err := config.LoadConfig(); err == nil {
+		for _, pkgStr := range cfg.Dependencies {
+			if sp, err := spec.Parse(pkgStr); err == nil {
+				seen[sp.URL] = true
+			}
+		}
+	}
+	for _, dep := range lock.Dependencies {
+		seen[dep.URL] = true
+	}
+	return sortedKeys(seen)
 }
 
-func discoverAllDependencies() (*discoveryResult, error) {
-	rootCfg, err := config.LoadConfig()
+// applyInstall brings cpp_modules, cppkg.lock and cppkg.cmake in line with a
+// resolved lock. Only packages whose commit differs from the install
+// manifest are installed; the others are carried over as they are. The
+// integrity of every package is recorded in the lock, except under frozen,
+// where the lock is never written. Everything is staged first, so that a
+// failure or Ctrl-C never leaves the project without its previous
+// dependencies.
+func applyInstall(sess *session, jobs int, lock *types.LockFile, frozen bool) error {
+	installed, err := config.LoadInstallManifest(config.GetModulesDir())
 	if err != nil {
-		return nil, err
-	}
-
-	result := &discoveryResult{
-		urls:        make(map[string]string),
-		constraints: make(map[string][]string),
+		return fmt.Errorf("could not read install manifest: %w", err)
+	}
+	cmakeData := generateCMakeFile(lock)
+
+	var changed, unchanged, removed []string
+	for _, name := range sortedKeys(lock.Dependencies) {
+		dep := lock.Dependencies[name]
+		pkg, ok := installed.Packages[name]
+		info, statErr := os.Lstat(filepath.Join(config.GetModulesDir(), name))
+		// A copied local path may be stale, a linked one never is.
+		stale := source.IsPath(dep.URL) && (statErr != nil || info.Mode()&os.ModeSymlink == 0)
+		if ok && pkg.Commit == revisionKey(dep) && statErr == nil && !stale {
+			if dep.Integrity == "" {
+				dep.Integrity = pkg.Hash
+				lock.Dependencies[name] = dep
+			}
+			unchanged = append(unchanged, name)
+		} else {
+			changed = append(changed, name)
+		}
 	}
-	queue := make([]string, 0)
-	processed := make(map[string]bool)
-
-	for name, pkgStr := range rootCfg.Dependencies {
-		url, constraint := parsePkgStr(pkgStr)
-		result.urls[name] = url
-		result.constraints[name] = append(result.constraints[name], constraint)
-		if !processed[name] {
-			queue = append(queue, name)
-			processed[name] = true
+	for _, name := range sortedKeys(installed.Packages) {
+		if _, ok := lock.Dependencies[name]; !ok {
+			removed = append(removed, name)
 		}
 	}
-
-	for len(queue) > 0 {
-		name := queue[0]
-		queue = queue[1:]
-
-		tempResolvedVersion, _, tempDir, err := resolveVersion(result.urls[name], result.constraints[name][0])
+	if len(changed) == 0 && len(removed) == 0 && fileHasContent(cmakeFilename, cmakeData) {
+		lockData, err := config.EncodeLockfile(lock)
 		if err != nil {
-			return nil, fmt.Errorf("could not temporarily resolve %s: %w", name, err)
+			return err
 		}
-
-		depCfgPath := filepath.Join(tempDir, config.ConfigFile)
-		if _, err := os.Stat(depCfgPath); !os.IsNotExist(err) {
-			depCfg, err := config.LoadConfigFromPath(depCfgPath)
-			if err != nil {
-				os.RemoveAll(tempDir)
-				return nil, fmt.Errorf("could not read cppkg.json for %s: %w", name, err)
-			}
-			fmt.Printf("  - Discovered dependencies in %s @ %s...\n", name, tempResolvedVersion)
-			for tName, tPkgStr := range depCfg.Dependencies {
-				tUrl, tConstraint := parsePkgStr(tPkgStr)
-				result.urls[tName] = tUrl
-				result.constraints[tName] = append(result.constraints[tName], tConstraint)
-				if !processed[tName] {
-					queue = append(queue, tName)
-					processed[tName] = true
-				}
-			}
+		if frozen || fileHasContent(config.LockFileName, lockData) {
+			fmt.Println("All packages are up to date.")
+			return nil
 		}
-		os.RemoveAll(tempDir)
 	}
-	return result, nil
-}
 
-func resolveConflicts(discovered *discoveryResult) (map[string]types.LockedDependency, error) {
-	finalDeps := make(map[string]types.LockedDependency)
-	for name, constraints := range discovered.constraints {
-		url := discovered.urls[name]
-		fmt.Printf("  - Resolving constraints for %s: %v\n", name, constraints)
-
-		bestVersions := make([]*semver.Version, 0)
-		for _, cons := range constraints {
-			resolved, _, tempDir, err := resolveVersion(url, cons)
-			if err != nil {
-				return nil, err
-			}
-			os.RemoveAll(tempDir)
-			v, err := semver.NewVersion(resolved)
-			if err != nil {
-				return nil, fmt.Errorf("resolved version %s for %s is not a valid semver", resolved, name)
-			}
-			bestVersions = append(bestVersions, v)
-		}
+	tx, err := beginTransaction()
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+	defer tx.rollbackOnInterrupt()()
 
-		highestVersion := bestVersions[0]
-		for i := 1; i < len(bestVersions); i++ {
-			if bestVersions[i].GreaterThan(highestVersion) {
-				highestVersion = bestVersions[i]
-			}
+	manifest := &types.InstallManifest{Packages: make(map[string]types.InstalledPackage)}
+	for _, name := range unchanged {
+		if err := tx.adopt(name); err != nil {
+			return fmt.Errorf("failed to stage package %s: %w", name, err)
 		}
+		manifest.Packages[name] = installed.Packages[name]
+	}
+	for _, name := range removed {
+		fmt.Printf("  - Removing %s\n", name)
+	}
 
-		finalVersionString := highestVersion.Original()
-		_, commit, tempDir, err := resolveVersion(url, finalVersionString)
+	if len(changed) > 0 {
+		fmt.Println("Installing packages...")
+	}
+	var mu sync.Mutex
+	err = parallel(jobs, len(changed), func(i int, out io.Writer) error {
+		mu.Lock()
+		name, dep := changed[i], lock.Dependencies[changed[i]]
+		mu.Unlock()
+		fmt.Fprintf(out, "  - Installing %s @ %s\n", name, dep.Version)
+		integrity, err := installPackage(sess, tx.modulesDir(), name, dep, out)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("failed to install package %s: %w", name, err)
 		}
-		os.RemoveAll(tempDir)
+		mu.Lock()
+		dep.Integrity = integrity
+		lock.Dependencies[name] = dep
+		manifest.Packages[name] = types.InstalledPackage{Commit: revisionKey(dep), Hash: integrity}
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := config.SaveInstallManifest(tx.modulesDir(), manifest); err != nil {
+		return fmt.Errorf("failed to write install manifest: %w", err)
+	}
 
-		finalDeps[name] = types.LockedDependency{
-			URL:     url,
-			Version: finalVersionString,
-			Commit:  commit,
+	if !frozen {
+		lockData, err := config.EncodeLockfile(lock)
+		if err != nil {
+			return err
 		}
+		tx.writeFile(config.LockFileName, lockData)
 	}
-	return finalDeps, nil
+	fmt.Printf("  - Generating %s\n", cmakeFilename)
+	tx.writeFile(cmakeFilename, cmakeData)
+	return tx.commit()
 }
 
-func resolveVersion(url, versionConstraint string) (string, string, string, error) {
-	tempDir, err := os.MkdirTemp("", "cppkg-resolve-*")
-	if err != nil {
-		return "", "", "", err
-	}
+// fileHasContent reports whether a file exists with exactly the given content.
+func fileHasContent(name string, data []byte) bool {
+	existing, err := os.ReadFile(name)
+	return err == nil && bytes.Equal(existing, data)
+}
 
-	// For temporary resolution, we don't need a progress bar, so pass nil.
-	if err := git.Clone(url, tempDir, nil); err != nil {
-		os.RemoveAll(tempDir)
-		return "", "", "", err
-	}
+// cachePath returns where the tree of a package revision is kept. The
+// revision is a commit, or the checksum of an archive.
+func cachePath(name, revision string) string {
+	revision = strings.TrimPrefix(revision, "sha256=")
+	return filepath.Join(config.GetCacheDir(), fmt.Sprintf("%s-%s", name, shortCommit(revision)))
+}
 
-	tags, err := git.ListTags(tempDir)
-	if err != nil {
-		os.RemoveAll(tempDir)
-		return "", "", "", fmt.Errorf("failed to list tags: %w", err)
+// installPackage copies a package into modulesDir from the project cache,
+// filling the cache from the package's source first if needed, and returns
+// the integrity of the installed tree. A cached tree that no longer matches
+// the locked integrity is discarded and fetched again; a freshly fetched
+// tree that does not match is an error.
+func installPackage(sess *session, modulesDir, name string, dep types.LockedDependency, out io.Writer) (string, error) {
+	if src, err := sess.source(dep.URL); err != nil {
+		return "", err
+	} else if local, ok := src.(source.Local); ok {
+		return "", linkLocal(local.Dir(), filepath.Join(modulesDir, name))
 	}
 
-	constraint, err := semver.NewConstraint(versionConstraint)
-	if err != nil {
-		commit, getErr := git.GetCommitHash(tempDir, versionConstraint)
-		if getErr != nil {
-			os.RemoveAll(tempDir)
-			return "", "", "", fmt.Errorf("version '%s' is not a valid semver range and not a valid tag/commit: %w", versionConstraint, getErr)
-		}
-		return versionConstraint, commit, tempDir, nil
-	}
+	pkgCachePath := cachePath(name, revisionKey(dep))
+	pkgDestPath := filepath.Join(modulesDir, name)
 
-	var bestVersion *semver.Version
-	for _, t := range tags {
-		v, err := semver.NewVersion(t)
-		if err == nil && constraint.Check(v) {
-			if bestVersion == nil || v.GreaterThan(bestVersion) {
-				bestVersion = v
-			}
+	if _, err := os.Stat(pkgCachePath); err == nil {
+		integrity, err := utils.HashDir(pkgCachePath)
+		if err != nil {
+			return "", err
+		}
+		if dep.Integrity == "" || integrity == dep.Integrity {
+			return integrity, git.CopyDir(pkgCachePath, pkgDestPath)
+		}
+		fmt.Fprintf(out, "  ! Cached copy of %s is corrupted, fetching it again\n", name)
+		if err := os.RemoveAll(pkgCachePath); err != nil {
+			return "", err
 		}
 	}
 
-	if bestVersion == nil {
-		os.RemoveAll(tempDir)
-		return "", "", "", fmt.Errorf("no version found that satisfies constraint '%s' for %s", versionConstraint, url)
+	rev := source.Revision{Version: dep.Version, Commit: dep.Commit, Checksum: dep.Checksum}
+	if err := sess.fetch(dep.URL, rev, pkgCachePath, out); err != nil {
+		os.RemoveAll(pkgCachePath)
+		return "", fmt.Errorf("failed to copy to cache: %w", err)
 	}
-
-	bestVersionString := bestVersion.Original()
-	commit, err := git.GetCommitHash(tempDir, bestVersionString)
+	integrity, err := utils.HashDir(pkgCachePath)
 	if err != nil {
-		os.RemoveAll(tempDir)
-		return "", "", "", fmt.Errorf("could not find commit for version %s: %w", bestVersionString, err)
+		return "", err
 	}
-	if err := git.Checkout(tempDir, commit); err != nil {
-		os.RemoveAll(tempDir)
-		return "", "", "", fmt.Errorf("could not checkout commit %s: %w", commit, err)
+	if dep.Integrity != "" && integrity != dep.Integrity {
+		os.RemoveAll(pkgCachePath)
+		return "", fmt.Errorf("integrity mismatch for %s: %s records %s, fetched %s", revisionKey(dep), config.LockFileName, dep.Integrity, integrity)
 	}
-
-	return bestVersionString, commit, tempDir, nil
+	return integrity, git.CopyDir(pkgCachePath, pkgDestPath)
 }
 
-func installPackage(name, url, commit string) error {
-	pkgCachePath := filepath.Join(config.GetCacheDir(), fmt.Sprintf("%s-%s", name, commit[:12]))
-	pkgDestPath := filepath.Join(config.GetModulesDir(), name)
-
-	if _, err := os.Stat(pkgCachePath); err == nil {
-		return git.CopyDir(pkgCachePath, pkgDestPath)
-	}
-
-	fmt.Printf("  -> Downloading %s from %s\n", name, url)
-	tempDir, err := os.MkdirTemp("", "cppkg-install-*")
+// linkLocal symlinks a local package into modulesDir, so that edits to it
+// are picked up without reinstalling. The link is relative, so that it
+// survives moving the project. Where symlinks are not available the package
+// is copied instead. Local packages have no integrity, since they are meant
+// to change.
+func linkLocal(dir, dest string) error {
+	abs, err := filepath.Abs(dir)
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(tempDir)
-
-	// Clone with progress bar by passing os.Stderr to the clone function.
-	if err := git.Clone(url, tempDir, os.Stderr); err != nil {
-		return err
-	}
-
-	if err := git.Checkout(tempDir, commit); err != nil {
+	destDir, err := filepath.Abs(filepath.Dir(dest))
+	if err != nil {
 		return err
 	}
-	if err := os.RemoveAll(filepath.Join(tempDir, ".git")); err != nil {
-		return err
+	target, err := filepath.Rel(destDir, abs)
+	if err != nil {
+		target = abs
 	}
-	if err := git.CopyDir(tempDir, pkgCachePath); err != nil {
-		return fmt.Errorf("failed to copy to cache: %w", err)
+	if err := os.Symlink(target, dest); err == nil {
+		return nil
 	}
-	return git.CopyDir(tempDir, pkgDestPath)
+	return git.CopyDir(dir, dest)
 }
 
-func generateCMakeFile(lockFile *types.LockFile) error {
-	cmakeFilename := "cppkg.cmake"
+// cmakeFilename is the CMake include file generated for the project.
+const cmakeFilename = "cppkg.cmake"
+
+func generateCMakeFile(lockFile *types.LockFile) []byte {
 	var contentBuilder strings.Builder
 
 	contentBuilder.WriteString("# This file is auto-generated by cppkg.\n")
 	contentBuilder.WriteString("# Do not edit this file manually.\n\n")
 	contentBuilder.WriteString("# Add include directories for all installed dependencies.\n")
 
-	for name := range lockFile.Dependencies {
+	for _, name := range sortedKeys(lockFile.Dependencies) {
 		includePath := filepath.Join(config.GetModulesDir(), name, "include")
 		cmakePath := fmt.Sprintf("include_directories(${CMAKE_CURRENT_SOURCE_DIR}/%s)\n", filepath.ToSlash(includePath))
 		contentBuilder.WriteString(cmakePath)
 	}
-
-	fmt.Printf("  - Generating %s\n", cmakeFilename)
-	return os.WriteFile(cmakeFilename, []byte(contentBuilder.String()), 0644)
+	return []byte(contentBuilder.String())
 }
 
 func runHooks(cfg *types.PackageConfig) error {
@@ -336,8 +368,3 @@ func runHooks(cfg *types.PackageConfig) error {
 
 	return cmd.Run()
 }
-
-func parsePkgStr(pkgStr string) (url, constraint string) {
-	parts := strings.Split(pkgStr, "#")
-	return parts[0], parts[1]
-}
@@ -0,0 +1,369 @@
+// File: cpp-package-manager/pkg/resolver/install_test.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/config"
+	"cpp-package-manager/pkg/git"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRepo is a git repository on the local disk that tests add commits and
+// tags to.
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	r := &testRepo{t: t, dir: t.TempDir()}
+	r.git("init", "-q", "-b", "main")
+	return r
+}
+
+func (r *testRepo) url() string {
+	return "file://" + r.dir
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = r.dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes files, where a value starting with "->" makes a symlink,
+// commits them and returns the new commit.
+func (r *testRepo) commit(files map[string]string) string {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		os.Remove(path)
+		var err error
+		if target, ok := strings.CutPrefix(content, "->"); ok {
+			err = os.Symlink(target, path)
+		} else {
+			err = os.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.git("add", "-A")
+	r.git("commit", "-q", "--allow-empty", "-m", "change")
+	return r.git("rev-parse", "HEAD")
+}
+
+// release commits files, along with a VERSION file holding the tag, and tags
+// the commit.
+func (r *testRepo) release(tag string, files map[string]string) string {
+	r.t.Helper()
+	withVersion := map[string]string{"VERSION": tag}
+	for name, content := range files {
+		withVersion[name] = content
+	}
+	commit := r.commit(withVersion)
+	r.git("tag", tag)
+	return commit
+}
+
+// inProject runs the test inside a fresh project directory whose cppkg.json
+// holds manifest, with the user-level cache redirected to a temporary one.
+func inProject(t *testing.T, manifest string) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, config.ConfigFile), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// lockedVersion returns the version of a package in cppkg.lock.
+func lockedVersion(t *testing.T, name string) string {
+	t.Helper()
+	lock, err := config.LoadLockfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lock.Dependencies[name].Version
+}
+
+func TestInstallReusesLockedCommits(t *testing.T) {
+	repo := newRepo(t)
+	repo.release("v1.0.0", map[string]string{"a.h": "1.0"})
+	inProject(t, `{"name": "p", "version": "0.1.0", "dependencies": {"a": "`+repo.url()+`#^1.0.0"}}`)
+
+	if err := InstallDependencies(InstallOptions{}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	repo.release("v1.1.0", map[string]string{"a.h": "1.1"})
+	if err := InstallDependencies(InstallOptions{}); err != nil {
+		t.Fatalf("second install: %v", err)
+	}
+	if got := lockedVersion(t, "a"); got != "v1.0.0" {
+		t.Errorf("install moved a to %s, want the locked v1.0.0", got)
+	}
+	if err := InstallDependencies(InstallOptions{Upgrade: true}); err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	if got := lockedVersion(t, "a"); got != "v1.1.0" {
+		t.Errorf("upgrade left a at %s, want v1.1.0", got)
+	}
+}
+
+func TestFrozenInstallRefusesToChangeLock(t *testing.T) {
+	a, b := newRepo(t), newRepo(t)
+	a.release("v1.0.0", nil)
+	b.release("v1.0.0", nil)
+	inProject(t, `{"name": "p", "version": "0.1.0", "dependencies": {"a": "`+a.url()+`#^1.0.0"}}`)
+	if err := InstallDependencies(InstallOptions{}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if err := InstallDependencies(InstallOptions{Frozen: true}); err != nil {
+		t.Fatalf("frozen install with an up-to-date lock: %v", err)
+	}
+
+	before, err := os.ReadFile(config.LockFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"name": "p", "version": "0.1.0", "dependencies": {"a": "` + a.url() + `#^1.0.0", "b": "` + b.url() + `#^1.0.0"}}`
+	if err := os.WriteFile(config.ConfigFile, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := InstallDependencies(InstallOptions{Frozen: true}); !errors.Is(err, ErrLockfileOutOfDate) {
+		t.Fatalf("frozen install with a new dependency: got %v, want ErrLockfileOutOfDate", err)
+	}
+	if after, _ := os.ReadFile(config.LockFileName); string(after) != string(before) {
+		t.Errorf("frozen install rewrote %s", config.LockFileName)
+	}
+}
+
+func TestInstallIntersectsConstraints(t *testing.T) {
+	a, b := newRepo(t), newRepo(t)
+	for _, tag := range []string{"v1.2.0", "v1.2.5", "v1.9.0"} {
+		a.release(tag, nil)
+	}
+	b.release("v1.0.0", map[string]string{config.ConfigFile: `{"name": "b", "version": "1.0.0", "dependencies": {"a": "` + a.url() + `#~1.2.0"}}`})
+	inProject(t, `{"name": "p", "version": "0.1.0", "dependencies": {"a": "`+a.url()+`#^1.2.0", "b": "`+b.url()+`#^1.0.0"}}`)
+
+	if err := InstallDependencies(InstallOptions{}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if got := lockedVersion(t, "a"); got != "v1.2.5" {
+		t.Errorf("a resolved to %s, want v1.2.5, the newest version allowed by both ^1.2.0 and ~1.2.0", got)
+	}
+}
+
+func TestInstallExplainsConflicts(t *testing.T) {
+	a, b, c := newRepo(t), newRepo(t), newRepo(t)
+	c.release("v1.4.0", nil)
+	c.release("v2.0.0", nil)
+	a.release("v1.0.0", map[string]string{config.ConfigFile: `{"name": "a", "version": "1.0.0", "dependencies": {"c": "` + c.url() + `#^2.0.0"}}`})
+	b.release("v1.0.0", map[string]string{config.ConfigFile: `{"name": "b", "version": "1.0.0", "dependencies": {"c": "` + c.url() + `#~1.4.0"}}`})
+	inProject(t, `{"name": "p", "version": "0.1.0", "dependencies": {"a": "`+a.url()+`#^1.0.0", "b": "`+b.url()+`#^1.0.0"}}`)
+
+	err := InstallDependencies(InstallOptions{})
+	var resErr *ResolutionError
+	if !errors.As(err, &resErr) {
+		t.Fatalf("install: got %v, want a ResolutionError", err)
+	}
+	for _, want := range []string{
+		"root -> a@v1.0.0 requires c ^2.0.0",
+		"root -> b@v1.0.0 requires c ~1.4.0",
+		"tags considered: v2.0.0, v1.4.0",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("conflict report does not contain %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestInstallOnlyTouchesChangedPackages(t *testing.T) {
+	a, b := newRepo(t), newRepo(t)
+	a.release("v1.0.0", nil)
+	b.release("v1.0.0", nil)
+	inProject(t, `{"name": "p", "version": "0.1.0", "dependencies": {"a": "`+a.url()+`#^1.0.0", "b": "`+b.url()+`#^1.0.0"}}`)
+	if err := InstallDependencies(InstallOptions{}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	stat := func(name string) os.FileInfo {
+		t.Helper()
+		info, err := os.Stat(filepath.Join(config.GetModulesDir(), name, "VERSION"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	aBefore, bBefore := stat("a"), stat("b")
+
+	b.release("v1.1.0", nil)
+	if err := InstallDependencies(InstallOptions{Upgrade: true}); err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	if !os.SameFile(aBefore, stat("a")) {
+		t.Error("a did not change, but was installed again")
+	}
+	if os.SameFile(bBefore, stat("b")) {
+		t.Error("b moved to v1.1.0, but was not installed again")
+	}
+	if data, _ := os.ReadFile(filepath.Join(config.GetModulesDir(), "b", "VERSION")); string(data) != "v1.1.0" {
+		t.Errorf("cpp_modules/b holds %q, want v1.1.0", data)
+	}
+}
+
+func TestInstallSelectors(t *testing.T) {
+	repo := newRepo(t)
+	tagged := repo.release("v1.0.0", nil)
+	head := repo.commit(map[string]string{"a.h": "next"})
+	inProject(t, `{"name": "p", "version": "0.1.0", "dependencies": {
+		"branch": "`+repo.url()+`#branch=main",
+		"commit": "`+repo.url()+`#commit=`+tagged[:10]+`",
+		"tag": "`+repo.url()+`#tag=v1.0.0"}}`)
+	locked := func() map[string]string {
+		t.Helper()
+		lock, err := config.LoadLockfile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits := make(map[string]string)
+		for name, dep := range lock.Dependencies {
+			commits[name] = dep.Commit
+		}
+		return commits
+	}
+
+	if err := InstallDependencies(InstallOptions{}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	want := map[string]string{"branch": head, "commit": tagged, "tag": tagged}
+	if got := locked(); !reflect.DeepEqual(got, want) {
+		t.Errorf("install locked %v, want %v", got, want)
+	}
+	if got := lockedVersion(t, "tag"); got != "v1.0.0" {
+		t.Errorf("tag=v1.0.0 locked version %s, want v1.0.0", got)
+	}
+
+	newHead := repo.commit(map[string]string{"a.h": "newer"})
+	if err := InstallDependencies(InstallOptions{}); err != nil {
+		t.Fatalf("second install: %v", err)
+	}
+	if got := locked()["branch"]; got != head {
+		t.Errorf("install moved branch=main to %s, want the locked %s", got, head)
+	}
+	if err := InstallDependencies(InstallOptions{Upgrade: true}); err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	want["branch"] = newHead
+	if got := locked(); !reflect.DeepEqual(got, want) {
+		t.Errorf("upgrade locked %v, want %v", got, want)
+	}
+}
+
+func TestInstallThenVerifyWithSymlinks(t *testing.T) {
+	s := newRepo(t)
+	s.release("v1.0.0", map[string]string{
+		"include/s.h":     "int s;\n",
+		"include/alias.h": "->s.h",
+		"headers":         "->include",
+	})
+	inProject(t, `{"name": "p", "version": "0.1.0", "dependencies": {"s": "`+s.url()+`#^1.0.0"}}`)
+
+	if err := InstallDependencies(InstallOptions{Jobs: 1}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if err := Verify(true, false); err != nil {
+		t.Fatalf("verify after a clean install: %v", err)
+	}
+	if info, err := os.Stat("cpp_modules"); err != nil {
+		t.Fatal(err)
+	} else if perm := info.Mode().Perm(); perm != 0755 {
+		t.Errorf("cpp_modules has mode %v, want 0755", perm)
+	}
+	for _, link := range []string{"include/alias.h", "headers"} {
+		info, err := os.Lstat(filepath.Join("cpp_modules", "s", link))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("cpp_modules/s/%s is not a symlink", link)
+		}
+	}
+}
+
+func TestVerifyWithUnreachableRemote(t *testing.T) {
+	a := newRepo(t)
+	a.release("v1.0.0", nil)
+	inProject(t, `{"name": "p", "dependencies": {"a": "`+a.url()+`#^1.0.0"}}`)
+	if err := InstallDependencies(InstallOptions{Jobs: 1}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if err := os.RemoveAll(a.dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify(false, false); err != nil {
+		t.Errorf("verify with an unreachable remote: %v, want only a warning", err)
+	}
+	if err := Verify(true, false); err == nil {
+		t.Error("verify --strict-tags with an unreachable remote succeeded, want an error")
+	}
+	if err := InstallDependencies(InstallOptions{Jobs: 1}); err != nil {
+		t.Errorf("install with an unreachable remote: %v, want only a warning", err)
+	}
+}
+
+func TestUninstallOffline(t *testing.T) {
+	a, b := newRepo(t), newRepo(t)
+	a.release("v1.0.0", nil)
+	b.release("v1.0.0", nil)
+	inProject(t, `{"name": "p", "dependencies": {"a": "`+a.url()+`#^1.0.0", "b": "`+b.url()+`#^1.0.0"}}`)
+	if err := InstallDependencies(InstallOptions{Jobs: 1}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	for _, dir := range []string{a.dir, b.dir} {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Online, --strict-tags would fail on the unreachable remote of a.
+	t.Cleanup(func() { git.SetOffline(false) })
+	if err := UninstallPackage("b", InstallOptions{Jobs: 1, StrictTags: true, Offline: true}); err != nil {
+		t.Fatalf("offline uninstall: %v", err)
+	}
+	lock, err := config.LoadLockfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lock.Dependencies["b"]; ok {
+		t.Error("b is still in cppkg.lock after uninstall")
+	}
+	if _, err := os.Stat(filepath.Join("cpp_modules", "b")); !os.IsNotExist(err) {
+		t.Errorf("cpp_modules/b was not removed: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+// File: cpp-package-manager/pkg/resolver/parallel.go
+package resolver
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// parallel runs task(i) for every i in [0, n) on at most jobs goroutines.
+// Each task writes its output to a private buffer; the buffers are flushed to
+// stdout in index order once every task has finished, so the output does not
+// depend on scheduling. After the first failure no new tasks are started,
+// but running ones are always waited for. The errors of all failed tasks are
+// joined in index order.
+func parallel(jobs, n int, task func(i int, out io.Writer) error) error {
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+	outputs := make([]bytes.Buffer, n)
+	errs := make([]error, n)
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := false
+
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := task(i, &outputs[i]); err != nil {
+				errs[i] = err
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range outputs {
+		io.Copy(os.Stdout, &outputs[i])
+	}
+	return errors.Join(errs...)
+}
+
+// memo caches the result of an expensive lookup per key. Concurrent callers
+// asking for the same key wait for a single computation.
+type memo[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*memoCall[T]
+}
+
+type memoCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+func (m *memo[T]) get(key string, fn func() (T, error)) (T, error) {
+	m.mu.Lock()
+	if m.calls == nil {
+		m.calls = make(map[string]*memoCall[T])
+	}
+	if c, ok := m.calls[key]; ok {
+		m.mu.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+	c := &memoCall[T]{done: make(chan struct{})}
+	m.calls[key] = c
+	m.mu.Unlock()
+
+	c.val, c.err = fn()
+	close(c.done)
+	return c.val, c.err
+}
@@ -0,0 +1,92 @@
+// File: cpp-package-manager/pkg/resolver/session.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/git"
+	"cpp-package-manager/pkg/source"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// session gives access to the source of every package touched during one
+// run. It is safe for concurrent use.
+type session struct {
+	jobs    int
+	open    source.Opener
+	sources memo[source.Source]
+
+	mu      sync.Mutex
+	missing []string
+}
+
+// newSession returns a session reading packages from their sources. Git's
+// progress output is only shown when packages are fetched one at a time,
+// since it would interleave otherwise.
+func newSession(jobs int) *session {
+	var progress io.Writer
+	if jobs == 1 {
+		progress = os.Stderr
+	}
+	return &session{jobs: jobs, open: source.NewOpener(progress)}
+}
+
+// source returns the source of a dependency URL.
+func (s *session) source(url string) (source.Source, error) {
+	return s.sources.get(url, func() (source.Source, error) {
+		return s.open(url)
+	})
+}
+
+// fetch writes the files of a package revision into dest.
+func (s *session) fetch(url string, rev source.Revision, dest string, out io.Writer) error {
+	src, err := s.source(url)
+	if err != nil {
+		return err
+	}
+	return s.noteMissing(src.Fetch(rev, dest, out))
+}
+
+// noteMissing records errors caused by offline mode, so that a failed run
+// can list everything that is missing from the cache rather than only the
+// first thing. It returns err unchanged.
+func (s *session) noteMissing(err error) error {
+	if errors.Is(err, git.ErrOffline) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, m := range s.missing {
+			if m == err.Error() {
+				return err
+			}
+		}
+		s.missing = append(s.missing, err.Error())
+	}
+	return err
+}
+
+// checkCached lists the versions of every url, so that offline mode can
+// report all missing packages at once rather than stopping at the first.
+func (s *session) checkCached(urls []string) {
+	for _, url := range urls {
+		if src, err := s.source(url); err == nil {
+			_, err = src.Versions()
+			s.noteMissing(err)
+		}
+	}
+}
+
+// missingReport lists everything offline mode could not find in the cache,
+// or returns "" if nothing was missing.
+func (s *session) missingReport() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.missing) == 0 {
+		return ""
+	}
+	missing := append([]string(nil), s.missing...)
+	sort.Strings(missing)
+	return "offline mode: the following are missing from the cache:\n  - " + strings.Join(missing, "\n  - ")
+}
@@ -0,0 +1,574 @@
+// File: cpp-package-manager/pkg/resolver/solver.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/config"
+	"cpp-package-manager/pkg/git"
+	"cpp-package-manager/pkg/source"
+	"cpp-package-manager/pkg/spec"
+	"cpp-package-manager/pkg/types"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// resolveGraph solves the dependency graph of the root cppkg.json and pins
+// every selected version to a commit, reusing locked commits where possible.
+// A plain install reuses the commits pinned in cppkg.lock; an upgrade ignores
+// them and resolves every range from scratch.
+func resolveGraph(sess *session, opts InstallOptions, lock *types.LockFile) (map[string]types.LockedDependency, error) {
+	rootCfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	s := &solver{reg: newRegistry(sess), jobs: opts.Jobs, root: rootCfg.Dependencies, allowCycles: rootCfg.AllowCycles}
+	if !opts.Upgrade {
+		s.preferred = lock.Dependencies
+	}
+	selected, reqs, err := s.solve()
+	if err != nil {
+		return nil, err
+	}
+
+	finalDeps := make(map[string]types.LockedDependency, len(selected))
+	reused := make(map[string]types.LockedDependency)
+	for _, name := range sortedKeys(selected) {
+		c := selected[name]
+		src, err := s.reg.sess.source(reqs[name][0].url)
+		if err != nil {
+			return nil, err
+		}
+		dep := types.LockedDependency{URL: src.ID(), Version: c.version, Commit: c.commit, Checksum: c.checksum}
+		if locked := lock.Dependencies[name]; locked.URL == dep.URL && locked.Version == dep.Version && revisionKey(locked) == revisionKey(dep) {
+			fmt.Printf("  - Using locked %s @ %s\n", name, dep.Version)
+			dep.Integrity = locked.Integrity
+			reused[name] = dep
+		} else {
+			fmt.Printf("  - Selected %s @ %s\n", name, dep.Version)
+		}
+		finalDeps[name] = dep
+	}
+	// Record the graph: which packages are required by cppkg.json, and what
+	// each package requires of the others.
+	for _, name := range sortedKeys(reqs) {
+		for _, req := range reqs[name] {
+			if req.parent == "" {
+				dep := finalDeps[name]
+				dep.Root = true
+				finalDeps[name] = dep
+				continue
+			}
+			dep := finalDeps[req.parent]
+			if dep.Dependencies == nil {
+				dep.Dependencies = make(map[string]string)
+			}
+			dep.Dependencies[name] = req.constraint
+			finalDeps[req.parent] = dep
+		}
+	}
+
+	// Only commits taken from the lock can be behind a moved tag; the others
+	// were just read from upstream. Offline, the mirrors only know the tags
+	// as they were last fetched, so there is nothing to compare against.
+	// Without --strict-tags, a remote that cannot be reached does not stop an
+	// install from the lock.
+	if git.IsOffline() {
+		return finalDeps, nil
+	}
+	moves, err := findMovedTags(s.reg, reused)
+	if err != nil {
+		if opts.StrictTags {
+			return nil, err
+		}
+		fmt.Printf("  ! WARNING: %s\n", err)
+	}
+	for _, m := range moves {
+		fmt.Printf("  ! WARNING: %s\n", m)
+	}
+	if opts.StrictTags && len(moves) > 0 {
+		return nil, fmt.Errorf("%d locked tag(s) moved upstream; refusing to continue under --strict-tags", len(moves))
+	}
+	return finalDeps, nil
+}
+
+// requirement is a single constraint placed on a package, either by the root
+// cppkg.json or by the manifest of another selected package.
+type requirement struct {
+	// parent is the name of the package that placed the requirement, or ""
+	// for the root cppkg.json. from labels it as "root" or "name@version".
+	parent     string
+	from       string
+	url        string
+	constraint string
+}
+
+// candidate is a version of a package together with the exact commit, or
+// archive checksum, it would be locked to. Manifests are always read at that
+// revision, so the discovered graph matches what ends up in cppkg.lock.
+type candidate struct {
+	version  string
+	commit   string
+	checksum string
+}
+
+// key identifies the revision of a candidate.
+func (c candidate) key() string {
+	if c.commit != "" {
+		return c.commit
+	}
+	return c.checksum
+}
+
+func (c candidate) revision() source.Revision {
+	return source.Revision{Version: c.version, Commit: c.commit, Checksum: c.checksum}
+}
+
+// registry memoizes the versions and manifests of package sources for the
+// duration of a single resolution. It is safe for concurrent use.
+type registry struct {
+	sess      *session
+	remote    memo[map[string]string]
+	tags      memo[[]candidate]
+	pins      memo[candidate]
+	manifests memo[map[string]string]
+}
+
+func newRegistry(sess *session) *registry {
+	return &registry{sess: sess}
+}
+
+// versions returns the semver tags of a package, newest first.
+func (r *registry) versions(url string) ([]candidate, error) {
+	return r.tags.get(url, func() ([]candidate, error) {
+		commits, err := r.tagCommits(url)
+		if err != nil {
+			return nil, err
+		}
+
+		versions := make([]*semver.Version, 0, len(commits))
+		for t := range commits {
+			if v, err := semver.NewVersion(t); err == nil {
+				versions = append(versions, v)
+			}
+		}
+		sort.Sort(sort.Reverse(semver.Collection(versions)))
+		sorted := make([]candidate, len(versions))
+		for i, v := range versions {
+			sorted[i] = candidate{version: v.Original(), commit: commits[v.Original()]}
+		}
+		return sorted, nil
+	})
+}
+
+// tagCommits maps every tag of a package to its commit.
+func (r *registry) tagCommits(url string) (map[string]string, error) {
+	return r.remote.get(url, func() (map[string]string, error) {
+		src, err := r.sess.source(url)
+		if err != nil {
+			return nil, err
+		}
+		revs, err := src.Versions()
+		if err != nil {
+			return nil, r.sess.noteMissing(err)
+		}
+		commits := make(map[string]string, len(revs))
+		for _, rev := range revs {
+			commits[rev.Version] = rev.Commit
+		}
+		return commits, nil
+	})
+}
+
+// pin resolves a tag, branch, commit or archive checksum that a package is
+// pinned to. Explicit selectors such as "branch=main" report their own errors.
+func (r *registry) pin(url, ref string, out io.Writer) (candidate, error) {
+	return r.pins.get(url+"#"+ref, func() (candidate, error) {
+		src, err := r.sess.source(url)
+		if err != nil {
+			return candidate{}, err
+		}
+		rev, err := src.Resolve(ref, out)
+		if err != nil && (source.IsArchive(url) || strings.Contains(ref, "=")) {
+			return candidate{}, r.sess.noteMissing(err)
+		}
+		if err != nil {
+			return candidate{}, fmt.Errorf("version '%s' is not a valid semver range and not a valid tag/commit: %w", ref, r.sess.noteMissing(err))
+		}
+		return candidate{version: rev.Version, commit: rev.Commit, checksum: rev.Checksum}, nil
+	})
+}
+
+// manifest returns the dependencies declared by a package at the given commit.
+// A package without a cppkg.json has no dependencies.
+func (r *registry) manifest(url string, c candidate, out io.Writer) (map[string]string, error) {
+	return r.manifests.get(url+"#"+c.key(), func() (map[string]string, error) {
+		src, err := r.sess.source(url)
+		if err != nil {
+			return nil, err
+		}
+		data, err := src.Manifest(c.revision(), out)
+		if err != nil {
+			return nil, r.sess.noteMissing(err)
+		}
+
+		fmt.Fprintf(out, "  - Reading dependencies of %s @ %s...\n", url, c.version)
+		deps := make(map[string]string)
+		if data != nil {
+			depCfg, err := config.ParseConfig(data)
+			if err != nil {
+				return nil, fmt.Errorf("could not read cppkg.json for %s @ %s: %w", url, c.version, err)
+			}
+			deps = depCfg.Dependencies
+		}
+		return deps, nil
+	})
+}
+
+// solver selects one version per package such that every requirement placed
+// on it by the root manifest and by the other selected versions holds. It
+// tries the newest candidates first and backtracks when a choice leaves
+// another package without a satisfying version.
+type solver struct {
+	reg       *registry
+	jobs      int
+	root      map[string]string
+	preferred map[string]types.LockedDependency
+	// allowCycles accepts packages that depend on each other in a cycle.
+	allowCycles bool
+	decisions   map[string]candidate
+	conflicts   []string
+}
+
+// solve returns the selected version of every package in the graph, along
+// with the requirements placed on each. The first requirement of a package
+// names its source URL.
+func (s *solver) solve() (map[string]candidate, map[string][]requirement, error) {
+	s.decisions = make(map[string]candidate)
+	s.conflicts = nil
+	ok, err := s.step()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, &ResolutionError{Conflicts: s.conflicts}
+	}
+	reqs, err := s.requirements()
+	if err != nil {
+		return nil, nil, err
+	}
+	if cycle := s.findCycle(reqs); cycle != nil {
+		if !s.allowCycles {
+			return nil, nil, &CycleError{Path: cycle}
+		}
+		fmt.Printf("  ! Allowing dependency cycle: %s\n", strings.Join(cycle, " -> "))
+	}
+	return s.decisions, reqs, nil
+}
+
+// step decides one more package and recurses, undoing the decision if no
+// selection of the remaining packages is consistent with it.
+func (s *solver) step() (bool, error) {
+	reqs, err := s.requirements()
+	if err != nil {
+		return false, err
+	}
+	for _, name := range sortedKeys(reqs) {
+		for _, req := range reqs[name][1:] {
+			if source.Identity(req.url) != source.Identity(reqs[name][0].url) {
+				s.recordSourceConflict(name, reqs)
+				return false, nil
+			}
+		}
+	}
+	for _, name := range sortedKeys(s.decisions) {
+		decided := s.decisions[name]
+		for _, req := range reqs[name] {
+			if !accepts(decided, req.constraint) {
+				s.recordConflict(name, decided.version, reqs, nil)
+				return false, nil
+			}
+		}
+	}
+
+	name := s.nextPackage(reqs)
+	if name == "" {
+		return true, nil
+	}
+	// A version pinned in cppkg.lock is tried before anything else, without
+	// asking the remote for its tags.
+	preferred, hasPreferred := s.preferredCandidate(name, reqs[name])
+	if hasPreferred {
+		if ok, err := s.try(name, preferred); err != nil || ok {
+			return ok, err
+		}
+	}
+
+	s.prefetch(reqs)
+	candidates, err := s.candidates(name, reqs[name], os.Stdout)
+	if err != nil {
+		return false, err
+	}
+	if len(candidates) == 0 {
+		considered, err := s.considered(reqs[name])
+		if err != nil {
+			return false, err
+		}
+		s.recordConflict(name, "", reqs, considered)
+		return false, nil
+	}
+	for _, c := range candidates {
+		if hasPreferred && c.key() == preferred.key() {
+			continue
+		}
+		if ok, err := s.try(name, c); err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// try decides a package at a candidate version and continues the search,
+// undoing the decision if it leads to a conflict.
+func (s *solver) try(name string, c candidate) (bool, error) {
+	s.decisions[name] = c
+	ok, err := s.step()
+	if err != nil || ok {
+		return ok, err
+	}
+	fmt.Printf("  - Backtracking: %s @ %s leads to a conflict\n", name, c.version)
+	delete(s.decisions, name)
+	return false, nil
+}
+
+// requirements collects the constraints placed on each package by the root
+// manifest and by the manifests of all decided versions.
+func (s *solver) requirements() (map[string][]requirement, error) {
+	reqs := make(map[string][]requirement)
+	for _, name := range sortedKeys(s.root) {
+		sp, err := parseDependency(config.ConfigFile, name, s.root[name])
+		if err != nil {
+			return nil, err
+		}
+		reqs[name] = append(reqs[name], requirement{from: "root", url: sp.URL, constraint: sp.Constraint()})
+	}
+	// A decided package is expanded once its own requirers are known, so that
+	// its URL comes from the requirement that introduced it.
+	expanded := make(map[string]bool, len(s.decisions))
+	for progress := true; progress; {
+		progress = false
+		for _, name := range sortedKeys(s.decisions) {
+			if expanded[name] || len(reqs[name]) == 0 {
+				continue
+			}
+			expanded[name], progress = true, true
+			c := s.decisions[name]
+			deps, err := s.reg.manifest(reqs[name][0].url, c, os.Stdout)
+			if err != nil {
+				return nil, fmt.Errorf("could not read dependencies of %s @ %s: %w", name, c.version, err)
+			}
+			from := fmt.Sprintf("%s@%s", name, c.version)
+			for _, tName := range sortedKeys(deps) {
+				sp, err := parseDependency(fmt.Sprintf("%s of %s", config.ConfigFile, from), tName, deps[tName])
+				if err != nil {
+					return nil, err
+				}
+				tUrl, err := relativeTo(reqs[name][0].url, sp.URL)
+				if err != nil {
+					return nil, fmt.Errorf("%s depends on %s: %w", from, tName, err)
+				}
+				reqs[tName] = append(reqs[tName], requirement{parent: name, from: from, url: tUrl, constraint: sp.Constraint()})
+			}
+		}
+	}
+	return reqs, nil
+}
+
+// parseDependency parses one entry of a manifest's dependencies, naming the
+// manifest and key in any error.
+func parseDependency(manifest, name, pkgStr string) (spec.Spec, error) {
+	if err := validName(name); err != nil {
+		return spec.Spec{}, fmt.Errorf("%s: dependency \"%s\": %w", manifest, name, err)
+	}
+	sp, err := spec.Parse(pkgStr)
+	if err != nil {
+		return spec.Spec{}, fmt.Errorf("%s: dependency \"%s\": %w", manifest, name, err)
+	}
+	return sp, nil
+}
+
+// validName checks that name can name a package. It becomes a directory in
+// cpp_modules and the cache, and "name@version" must split back into both.
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\#@`) {
+		return fmt.Errorf("invalid package name '%s'", name)
+	}
+	return nil
+}
+
+// relativeTo rewrites a path dependency declared by the package at parent so
+// that it is relative to the project root, like the paths in cppkg.json. Only
+// local packages may depend on local paths; anywhere else the path would
+// point into whatever directory the project happens to live in.
+func relativeTo(parent, url string) (string, error) {
+	if !source.IsPath(url) {
+		return url, nil
+	}
+	if !source.IsPath(parent) {
+		return "", fmt.Errorf("%s is a local path, but only local packages can depend on local paths", url)
+	}
+	dir := strings.TrimPrefix(url, source.PathPrefix)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(strings.TrimPrefix(parent, source.PathPrefix), dir)
+	}
+	return source.PathPrefix + filepath.ToSlash(dir), nil
+}
+
+// prefetch fetches, concurrently, the tags of every undecided package and
+// the manifest of its most likely candidate, so that the sequential search
+// that follows is served from the registry's memo. Failures are left for the
+// search to report in context.
+func (s *solver) prefetch(reqs map[string][]requirement) {
+	var pending []string
+	for _, name := range sortedKeys(reqs) {
+		if _, ok := s.decisions[name]; !ok {
+			pending = append(pending, name)
+		}
+	}
+	if len(pending) < 2 {
+		return
+	}
+	parallel(s.jobs, len(pending), func(i int, out io.Writer) error {
+		name := pending[i]
+		if c, ok := s.preferredCandidate(name, reqs[name]); ok {
+			_, err := s.reg.manifest(reqs[name][0].url, c, out)
+			return err
+		}
+		candidates, err := s.candidates(name, reqs[name], out)
+		if err != nil || len(candidates) == 0 {
+			return err
+		}
+		_, err = s.reg.manifest(reqs[name][0].url, candidates[0], out)
+		return err
+	})
+}
+
+// nextPackage returns the first required package that has not been decided.
+func (s *solver) nextPackage(reqs map[string][]requirement) string {
+	for _, name := range sortedKeys(reqs) {
+		if _, ok := s.decisions[name]; !ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// preferredCandidate returns the locked version of a package if it is still
+// acceptable: same source and satisfying every requirement. It keeps its
+// locked commit even if the tag has since moved.
+func (s *solver) preferredCandidate(name string, reqs []requirement) (candidate, bool) {
+	locked, ok := s.preferred[name]
+	if !ok || source.Identity(locked.URL) != source.Identity(reqs[0].url) || revisionKey(locked) == "" {
+		return candidate{}, false
+	}
+	c := candidate{version: locked.Version, commit: locked.Commit, checksum: locked.Checksum}
+	for _, req := range reqs {
+		if !accepts(c, req.constraint) {
+			return candidate{}, false
+		}
+	}
+	return c, true
+}
+
+// candidates lists the versions of a package that satisfy every requirement
+// placed on it, newest first.
+func (s *solver) candidates(name string, reqs []requirement, out io.Writer) ([]candidate, error) {
+	url := reqs[0].url
+	// A tag, branch, commit or checksum pins the package to exactly that
+	// ref. A local path has only the one version it resolves to.
+	pinned, hasPin := "", source.IsPath(url)
+	for _, req := range reqs {
+		if _, err := semver.NewConstraint(req.constraint); err != nil && req.constraint != "" {
+			pinned, hasPin = req.constraint, true
+			break
+		}
+	}
+	if hasPin {
+		c, err := s.reg.pin(url, pinned, out)
+		if err != nil {
+			return nil, err
+		}
+		for _, req := range reqs {
+			if !accepts(c, req.constraint) {
+				return nil, nil
+			}
+		}
+		return []candidate{c}, nil
+	}
+
+	versions, err := s.reg.versions(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not list versions of %s: %w", name, err)
+	}
+	candidates := make([]candidate, 0, len(versions))
+	for _, c := range versions {
+		ok := true
+		for _, req := range reqs {
+			ok = ok && accepts(c, req.constraint)
+		}
+		if ok {
+			candidates = append(candidates, c)
+		}
+	}
+
+	return candidates, nil
+}
+
+// accepts reports whether a candidate meets a constraint. An archive
+// checksum only accepts the archive with exactly that checksum; a missing
+// constraint, as on a local path, accepts anything.
+func accepts(c candidate, constraint string) bool {
+	if constraint == "" {
+		return true
+	}
+	if strings.HasPrefix(constraint, "sha256=") {
+		return strings.EqualFold(c.checksum, constraint)
+	}
+	return satisfies(c.version, constraint)
+}
+
+// revisionKey identifies the exact revision of a locked package: its commit,
+// or the checksum of an archive.
+func revisionKey(dep types.LockedDependency) string {
+	if dep.Commit != "" {
+		return dep.Commit
+	}
+	return dep.Checksum
+}
+
+// satisfies reports whether a version meets a constraint. Constraints that
+// are not semver ranges name a tag, branch or commit and must match exactly;
+// an explicit "tag=" selector matches the bare tag.
+func satisfies(version, constraint string) bool {
+	constraint = strings.TrimPrefix(constraint, "tag=")
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return version == constraint
+	}
+	v, err := semver.NewVersion(version)
+	return err == nil && c.Check(v)
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,226 @@
+// File: cpp-package-manager/pkg/resolver/solver_test.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/source"
+	"cpp-package-manager/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeSource is an in-memory package: each tag maps to the dependencies its
+// cppkg.json declares. Old commits, that no tag points at any more, can have
+// manifests of their own.
+type fakeSource struct {
+	url  string
+	tags map[string]map[string]string
+	old  map[string]map[string]string
+}
+
+func (f *fakeSource) ID() string {
+	return f.url
+}
+
+func (f *fakeSource) Versions() ([]source.Revision, error) {
+	revs := make([]source.Revision, 0, len(f.tags))
+	for tag := range f.tags {
+		revs = append(revs, f.revision(tag))
+	}
+	return revs, nil
+}
+
+func (f *fakeSource) Resolve(ref string, out io.Writer) (source.Revision, error) {
+	if _, ok := f.tags[ref]; !ok {
+		return source.Revision{}, fmt.Errorf("no tag %s in %s", ref, f.url)
+	}
+	return f.revision(ref), nil
+}
+
+func (f *fakeSource) Manifest(rev source.Revision, out io.Writer) ([]byte, error) {
+	deps, ok := f.old[rev.Commit]
+	if !ok {
+		deps = f.tags[rev.Version]
+	}
+	return json.Marshal(map[string]interface{}{"name": f.url, "dependencies": deps})
+}
+
+func (f *fakeSource) Fetch(rev source.Revision, dest string, out io.Writer) error {
+	return errors.New("fake sources cannot be fetched")
+}
+
+func (f *fakeSource) revision(tag string) source.Revision {
+	return source.Revision{Version: tag, Commit: "commit-of-" + f.url + "-" + tag}
+}
+
+// fakeOpener serves packages from memory, keyed by URL.
+func fakeOpener(packages map[string]map[string]map[string]string) source.Opener {
+	return func(url string) (source.Source, error) {
+		tags, ok := packages[url]
+		if !ok {
+			return nil, fmt.Errorf("no package at %s", url)
+		}
+		return &fakeSource{url: url, tags: tags}, nil
+	}
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages map[string]map[string]map[string]string
+		root     map[string]string
+		want     map[string]string
+		wantErr  interface{}
+	}{
+		{
+			name: "intersects constraints instead of taking the highest best",
+			packages: map[string]map[string]map[string]string{
+				"https://x/a": {"v1.2.0": nil, "v1.2.5": nil, "v1.9.0": nil},
+				"https://x/b": {"v1.0.0": {"a": "https://x/a#~1.2.0"}},
+			},
+			root: map[string]string{"a": "https://x/a#^1.2.0", "b": "https://x/b#^1.0.0"},
+			want: map[string]string{"a": "v1.2.5", "b": "v1.0.0"},
+		},
+		{
+			name: "backtracks out of a version whose dependencies conflict",
+			packages: map[string]map[string]map[string]string{
+				"https://x/a": {"v1.0.0": nil, "v2.0.0": nil},
+				"https://x/b": {
+					"v1.0.0": {"a": "https://x/a#^1.0.0"},
+					"v1.1.0": {"a": "https://x/a#^2.0.0"},
+				},
+			},
+			root: map[string]string{"a": "https://x/a#^1.0.0", "b": "https://x/b#^1.0.0"},
+			want: map[string]string{"a": "v1.0.0", "b": "v1.0.0"},
+		},
+		{
+			name: "discovers dependencies of the version actually chosen",
+			packages: map[string]map[string]map[string]string{
+				"https://x/a": {"v1.0.0": {"c": "https://x/c#^1.0.0"}, "v2.0.0": nil},
+				"https://x/c": {"v1.0.0": nil},
+			},
+			root: map[string]string{"a": "https://x/a#^1.0.0"},
+			want: map[string]string{"a": "v1.0.0", "c": "v1.0.0"},
+		},
+		{
+			name: "different spellings of one repository are one source",
+			packages: map[string]map[string]map[string]string{
+				"https://github.com/x/a": {"v1.0.0": nil},
+				"https://x/b":            {"v1.0.0": {"a": "git@github.com:x/a.git#^1.0.0"}},
+				"git@github.com:x/a.git": {"v1.0.0": nil},
+			},
+			root: map[string]string{"a": "https://github.com/x/a#^1.0.0", "b": "https://x/b#^1.0.0"},
+			want: map[string]string{"a": "v1.0.0", "b": "v1.0.0"},
+		},
+		{
+			name: "no version satisfies every constraint",
+			packages: map[string]map[string]map[string]string{
+				"https://x/a": {"v1.0.0": nil, "v2.0.0": nil},
+				"https://x/b": {"v1.0.0": {"a": "https://x/a#^2.0.0"}},
+			},
+			root:    map[string]string{"a": "https://x/a#^1.0.0", "b": "https://x/b#^1.0.0"},
+			wantErr: new(*ResolutionError),
+		},
+		{
+			name: "one name from two sources",
+			packages: map[string]map[string]map[string]string{
+				"https://x/a":    {"v1.0.0": nil},
+				"https://fork/a": {"v1.0.0": nil},
+				"https://x/b":    {"v1.0.0": {"a": "https://fork/a#^1.0.0"}},
+			},
+			root:    map[string]string{"a": "https://x/a#^1.0.0", "b": "https://x/b#^1.0.0"},
+			wantErr: new(*ResolutionError),
+		},
+		{
+			name: "cycle",
+			packages: map[string]map[string]map[string]string{
+				"https://x/a": {"v1.0.0": {"b": "https://x/b#^1.0.0"}},
+				"https://x/b": {"v1.0.0": {"a": "https://x/a#^1.0.0"}},
+			},
+			root:    map[string]string{"a": "https://x/a#^1.0.0"},
+			wantErr: new(*CycleError),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := &session{jobs: 1, open: fakeOpener(tt.packages)}
+			s := &solver{reg: newRegistry(sess), jobs: 1, root: tt.root}
+			selected, _, err := s.solve()
+			if tt.wantErr != nil {
+				if !errors.As(err, tt.wantErr) {
+					t.Fatalf("solve() error = %v, want %T", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("solve() error = %v", err)
+			}
+			got := make(map[string]string, len(selected))
+			for name, c := range selected {
+				got[name] = c.version
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("solve() selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDependency(t *testing.T) {
+	for _, name := range []string{"", "..", "../../x", `a\b`, "a@b"} {
+		_, err := parseDependency("cppkg.json of liba@v1.0.0", name, "https://x/a#^1.0.0")
+		if err == nil {
+			t.Errorf("parseDependency accepted the name %q", name)
+		} else if !strings.Contains(err.Error(), `cppkg.json of liba@v1.0.0: dependency "`+name+`"`) {
+			t.Errorf("parseDependency(%q) error = %v, want it to name the manifest and key", name, err)
+		}
+	}
+	if _, err := parseDependency("cppkg.json", "fmt", "https://x/fmt#^10.0.0"); err != nil {
+		t.Errorf("parseDependency(fmt) error = %v", err)
+	}
+}
+
+func TestResolveGraphRecordsSourceID(t *testing.T) {
+	inProject(t, `{"name": "p", "dependencies": {"a": "https://x/a.git#^1.0.0"}}`)
+	open := func(url string) (source.Source, error) {
+		return &fakeSource{url: "https://x/a", tags: map[string]map[string]string{"v1.0.0": nil}}, nil
+	}
+	deps, err := resolveGraph(&session{jobs: 1, open: open}, InstallOptions{Jobs: 1}, &types.LockFile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := deps["a"].URL; got != "https://x/a" {
+		t.Errorf("locked URL = %s, want the source's ID https://x/a", got)
+	}
+}
+
+func TestSolveTriesNewBranchHead(t *testing.T) {
+	packages := map[string]*fakeSource{
+		"https://x/a": {
+			url:  "https://x/a",
+			tags: map[string]map[string]string{"branch=main": {"c": "https://x/c#^2.0.0"}},
+			old:  map[string]map[string]string{"old-head": {"c": "https://x/c#^1.0.0"}},
+		},
+		"https://x/b": {url: "https://x/b", tags: map[string]map[string]string{"v1.0.0": {"c": "https://x/c#^2.0.0"}}},
+		"https://x/c": {url: "https://x/c", tags: map[string]map[string]string{"v1.0.0": nil, "v2.0.0": nil}},
+	}
+	open := func(url string) (source.Source, error) {
+		return packages[url], nil
+	}
+	s := &solver{
+		reg:       newRegistry(&session{jobs: 1, open: open}),
+		jobs:      1,
+		root:      map[string]string{"a": "https://x/a#branch=main", "b": "https://x/b#^1.0.0"},
+		preferred: map[string]types.LockedDependency{"a": {URL: "https://x/a", Version: "branch=main", Commit: "old-head"}},
+	}
+	selected, _, err := s.solve()
+	if err != nil {
+		t.Fatalf("solve() error = %v", err)
+	}
+	if got, want := selected["a"].commit, "commit-of-https://x/a-branch=main"; got != want {
+		t.Errorf("a was locked to %s, want the new branch head %s", got, want)
+	}
+}
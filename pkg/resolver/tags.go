@@ -0,0 +1,68 @@
+// File: cpp-package-manager/pkg/resolver/tags.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/config"
+	"cpp-package-manager/pkg/types"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// tagMove records a locked tag that upstream has since re-pointed at a
+// different commit.
+type tagMove struct {
+	name    string
+	tag     string
+	locked  string
+	current string
+}
+
+func (m tagMove) String() string {
+	return fmt.Sprintf("tag %s of %s has moved: %s pins %s, but the remote now points to %s",
+		m.tag, m.name, config.LockFileName, shortCommit(m.locked), shortCommit(m.current))
+}
+
+// findMovedTags compares the commit of every locked dependency with the
+// commit its tag points to upstream, asking every remote in parallel. Remotes
+// whose tags were already listed during this run are not asked again.
+// Dependencies pinned to a commit or tracking a branch have no tag and are
+// skipped.
+func findMovedTags(reg *registry, locked map[string]types.LockedDependency) ([]tagMove, error) {
+	names := sortedKeys(locked)
+	moves := make([]*tagMove, len(names))
+	check := func(i int, out io.Writer) error {
+		dep := locked[names[i]]
+		tag := dep.Version
+		if dep.Commit == "" || strings.HasPrefix(tag, "branch=") || strings.HasPrefix(tag, "commit=") {
+			return nil
+		}
+		commits, err := reg.tagCommits(dep.URL)
+		if err != nil {
+			return fmt.Errorf("could not check tags of %s: %w", names[i], err)
+		}
+		if current, ok := commits[tag]; ok && current != dep.Commit {
+			moves[i] = &tagMove{name: names[i], tag: tag, locked: dep.Commit, current: current}
+		}
+		return nil
+	}
+	if err := parallel(reg.sess.jobs, len(names), check); err != nil {
+		return nil, err
+	}
+
+	var found []tagMove
+	for _, m := range moves {
+		if m != nil {
+			found = append(found, *m)
+		}
+	}
+	return found, nil
+}
+
+// shortCommit abbreviates a commit hash for display.
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
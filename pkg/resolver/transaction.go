@@ -0,0 +1,188 @@
+// File: cpp-package-manager/pkg/resolver/transaction.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/config"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// stagingPattern names the directories an install is staged in, next to the
+// live modules directory so that swapping them in is a cheap rename.
+const stagingPattern = ".cpp_modules-staging-*"
+
+// transaction stages an install next to the live project files and swaps it
+// in only once every package, the lock file and cppkg.cmake are ready. Until
+// commit the project is untouched; if the swap itself fails, or the run is
+// interrupted, the previous state is restored.
+type transaction struct {
+	mu       sync.Mutex
+	stageDir string
+	adopted  []string
+	files    map[string][]byte
+	order    []string
+	undo     []func() error
+	done     bool
+}
+
+// beginTransaction creates a fresh staging directory, removing any left
+// behind by a run that was killed outright.
+func beginTransaction() (*transaction, error) {
+	stale, _ := filepath.Glob(stagingPattern)
+	for _, dir := range stale {
+		os.RemoveAll(dir)
+	}
+	stageDir, err := os.MkdirTemp(".", stagingPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	// MkdirTemp creates the directory private to the user; it becomes
+	// cpp_modules, which should be as readable as any other directory.
+	if err := os.Chmod(stageDir, 0755); err != nil {
+		os.RemoveAll(stageDir)
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	return &transaction{stageDir: stageDir, files: make(map[string][]byte)}, nil
+}
+
+// modulesDir returns the staging directory packages are installed into.
+func (t *transaction) modulesDir() string {
+	return t.stageDir
+}
+
+// adopt keeps an already installed package, so that it does not have to be
+// reinstalled. It stays in the live modules directory, where the project can
+// still use it, until commit moves it into the staging directory.
+func (t *transaction) adopt(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := os.Lstat(filepath.Join(config.GetModulesDir(), name)); err != nil {
+		return err
+	}
+	t.adopted = append(t.adopted, name)
+	return nil
+}
+
+// writeFile stages the new contents of a project file.
+func (t *transaction) writeFile(name string, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.files[name]; !ok {
+		t.order = append(t.order, name)
+	}
+	t.files[name] = data
+}
+
+// commit swaps the staged modules directory and files into place. Every
+// step registers how to undo itself, so a failure part-way through leaves
+// the project exactly as it was.
+func (t *transaction) commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return nil
+	}
+
+	for _, name := range t.order {
+		if err := os.WriteFile(name+".tmp", t.files[name], 0644); err != nil {
+			t.abort()
+			return err
+		}
+	}
+
+	modulesDir := config.GetModulesDir()
+	for _, name := range t.adopted {
+		live := filepath.Join(modulesDir, name)
+		staged := filepath.Join(t.stageDir, name)
+		if err := os.Rename(live, staged); err != nil {
+			t.abort()
+			return fmt.Errorf("failed to keep package %s: %w", name, err)
+		}
+		t.undo = append(t.undo, func() error { return os.Rename(staged, live) })
+	}
+	backupDir := modulesDir + ".old"
+	if err := t.replace(modulesDir, t.stageDir, backupDir); err != nil {
+		t.abort()
+		return fmt.Errorf("failed to swap in %s: %w", modulesDir, err)
+	}
+	for _, name := range t.order {
+		if err := t.replace(name, name+".tmp", name+".old"); err != nil {
+			t.abort()
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	os.RemoveAll(backupDir)
+	for _, name := range t.order {
+		os.Remove(name + ".old")
+	}
+	t.done = true
+	return nil
+}
+
+// replace moves src to target, first moving any existing target to backup.
+func (t *transaction) replace(target, src, backup string) error {
+	os.RemoveAll(backup)
+	if _, err := os.Lstat(target); err == nil {
+		if err := os.Rename(target, backup); err != nil {
+			return err
+		}
+		t.undo = append(t.undo, func() error { return os.Rename(backup, target) })
+	}
+	if err := os.Rename(src, target); err != nil {
+		return err
+	}
+	t.undo = append(t.undo, func() error { return os.Rename(target, src) })
+	return nil
+}
+
+// abort undoes every completed step of a commit in reverse order and removes
+// everything that was staged. The caller must hold t.mu.
+func (t *transaction) abort() {
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		if err := t.undo[i](); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not restore previous install: %v\n", err)
+		}
+	}
+	t.undo = nil
+	os.RemoveAll(t.stageDir)
+	for _, name := range t.order {
+		os.Remove(name + ".tmp")
+	}
+	t.done = true
+}
+
+// rollback discards the transaction unless it has already been committed.
+func (t *transaction) rollback() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.done {
+		t.abort()
+	}
+}
+
+// rollbackOnInterrupt restores the previous state and exits if the user
+// presses Ctrl-C before the transaction is committed. The returned function
+// stops watching for the signal.
+func (t *transaction) rollbackOnInterrupt() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			t.rollback()
+			fmt.Fprintln(os.Stderr, "\nInterrupted, the previous install was left in place.")
+			os.Exit(130)
+		case <-stopped:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(stopped)
+	}
+}
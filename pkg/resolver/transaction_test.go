@@ -0,0 +1,40 @@
+// File: cpp-package-manager/pkg/resolver/transaction_test.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdoptLeavesPackageInPlaceUntilCommit(t *testing.T) {
+	inProject(t, `{"name": "p", "dependencies": {}}`)
+	kept := filepath.Join(config.GetModulesDir(), "a", "a.h")
+	if err := os.MkdirAll(filepath.Dir(kept), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kept, []byte("int a;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := beginTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.adopt("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Fatalf("adopt moved the package out of %s before commit: %v", config.GetModulesDir(), err)
+	}
+	if err := tx.commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("package was not kept by commit: %v", err)
+	}
+	if stale, _ := filepath.Glob(stagingPattern); len(stale) != 0 {
+		t.Errorf("staging directories left behind: %v", stale)
+	}
+}
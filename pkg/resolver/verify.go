@@ -0,0 +1,108 @@
+// File: cpp-package-manager/pkg/resolver/verify.go
+package resolver
+
+import (
+	"cpp-package-manager/pkg/config"
+	"cpp-package-manager/pkg/git"
+	"cpp-package-manager/pkg/source"
+	"cpp-package-manager/pkg/utils"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ErrVerifyFailed is returned by Verify when an installed or cached package
+// does not match cppkg.lock.
+var ErrVerifyFailed = errors.New("some packages do not match cppkg.lock")
+
+// Verify checks every package in cpp_modules, and its copy in .cppkg_cache
+// if there is one, against the integrity recorded in cppkg.lock. It also
+// checks that every locked tag still points at the locked commit upstream;
+// a moved tag, or a remote that cannot be reached, is only a warning unless
+// strictTags is set.
+func Verify(strictTags, offline bool) error {
+	git.SetOffline(offline)
+	lock, err := config.LoadLockfile()
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", config.LockFileName, err)
+	}
+	if len(lock.Dependencies) == 0 {
+		fmt.Printf("No packages in %s.\n", config.LockFileName)
+		return nil
+	}
+
+	fmt.Println("Verifying installed packages...")
+	problems := 0
+	for _, name := range sortedKeys(lock.Dependencies) {
+		dep := lock.Dependencies[name]
+		label := fmt.Sprintf("%s @ %s", name, dep.Version)
+		if source.IsPath(dep.URL) {
+			fmt.Printf("  - %s: local path, not verified\n", label)
+			continue
+		}
+		if dep.Integrity == "" {
+			fmt.Printf("  ? %s: no integrity recorded in %s, run 'cppkg install' to add it\n", label, config.LockFileName)
+			continue
+		}
+
+		ok := true
+		installedPath := filepath.Join(config.GetModulesDir(), name)
+		if _, err := os.Stat(installedPath); err != nil {
+			fmt.Printf("  ! %s: %s is missing\n", label, installedPath)
+			ok = false
+		} else if integrity, err := utils.HashDir(installedPath); err != nil {
+			return err
+		} else if integrity != dep.Integrity {
+			fmt.Printf("  ! %s: %s was modified\n", label, installedPath)
+			ok = false
+		}
+
+		if pkgCachePath := cachePath(name, revisionKey(dep)); dirExists(pkgCachePath) {
+			if integrity, err := utils.HashDir(pkgCachePath); err != nil {
+				return err
+			} else if integrity != dep.Integrity {
+				fmt.Printf("  ! %s: cached copy %s is corrupted\n", label, pkgCachePath)
+				ok = false
+			}
+		}
+
+		if ok {
+			fmt.Printf("  - %s: ok\n", label)
+		} else {
+			problems++
+		}
+	}
+
+	if git.IsOffline() {
+		fmt.Println("Skipping the upstream tag check in offline mode.")
+	} else {
+		fmt.Println("Checking locked tags upstream...")
+		moves, err := findMovedTags(newRegistry(newSession(runtime.NumCPU())), lock.Dependencies)
+		if err != nil {
+			if strictTags {
+				return err
+			}
+			fmt.Printf("  ! WARNING: %s\n", err)
+		}
+		for _, m := range moves {
+			fmt.Printf("  ! WARNING: %s\n", m)
+		}
+		if strictTags {
+			problems += len(moves)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%w (%d problems found)", ErrVerifyFailed, problems)
+	}
+	fmt.Println("All packages match cppkg.lock.")
+	return nil
+}
+
+// dirExists reports whether path exists and is a directory.
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
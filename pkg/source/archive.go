@@ -0,0 +1,274 @@
+// File: cpp-package-manager/pkg/source/archive.go
+package source
+
+import (
+	"compress/gzip"
+	"cpp-package-manager/pkg/config"
+	"cpp-package-manager/pkg/git"
+	"cpp-package-manager/pkg/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// archiveExts are the release archive formats cppkg can unpack.
+var archiveExts = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+// IsArchive reports whether url points to a release archive rather than a
+// git repository.
+func IsArchive(url string) bool {
+	return archiveExt(url) != ""
+}
+
+func archiveExt(url string) string {
+	name := strings.ToLower(archiveName(url))
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(name, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// archiveName returns the file name of an archive URL, ignoring any query.
+func archiveName(url string) string {
+	if u, err := neturl.Parse(url); err == nil {
+		return path.Base(u.Path)
+	}
+	return path.Base(url)
+}
+
+// Archives opens release archives fetched over http(s) or from file:// URLs.
+// An archive has no tags: it is pinned by the sha256 of its contents, and is
+// unpacked once into the user cache, keyed by that checksum. It is safe for
+// concurrent use.
+type Archives struct {
+	mu      sync.Mutex
+	unpacks map[string]*unpack
+}
+
+type unpack struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// NewArchives returns an Archives with nothing unpacked yet.
+func NewArchives() *Archives {
+	return &Archives{unpacks: make(map[string]*unpack)}
+}
+
+// Open returns the source of the archive at url.
+func (a *Archives) Open(url string) (Source, error) {
+	return &archiveSource{a: a, url: url}, nil
+}
+
+type archiveSource struct {
+	a   *Archives
+	url string
+}
+
+func (s *archiveSource) ID() string {
+	return s.url
+}
+
+func (s *archiveSource) Versions() ([]Revision, error) {
+	return nil, fmt.Errorf("%s is an archive and has no versions; pin it with '#sha256=<checksum>'", s.url)
+}
+
+// Resolve downloads the archive and checks it against a "sha256=<hex>" ref.
+// The version is taken from the file name, e.g. "1.2.3" for foo-1.2.3.tar.gz.
+func (s *archiveSource) Resolve(ref string, out io.Writer) (Revision, error) {
+	sum, err := parseChecksum(ref)
+	if err != nil {
+		return Revision{}, err
+	}
+	if _, err := s.a.unpack(s.url, sum, out); err != nil {
+		return Revision{}, err
+	}
+	return Revision{Version: archiveVersion(s.url), Checksum: "sha256=" + sum}, nil
+}
+
+func (s *archiveSource) Manifest(rev Revision, out io.Writer) ([]byte, error) {
+	dir, err := s.unpacked(rev, out)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, config.ConfigFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *archiveSource) Fetch(rev Revision, dest string, out io.Writer) error {
+	dir, err := s.unpacked(rev, out)
+	if err != nil {
+		return err
+	}
+	return git.CopyDir(dir, dest)
+}
+
+func (s *archiveSource) unpacked(rev Revision, out io.Writer) (string, error) {
+	sum, err := parseChecksum(rev.Checksum)
+	if err != nil {
+		return "", err
+	}
+	return s.a.unpack(s.url, sum, out)
+}
+
+// parseChecksum returns the hex digest of a "sha256=<hex>" ref.
+func parseChecksum(ref string) (string, error) {
+	algo, sum, ok := strings.Cut(ref, "=")
+	sum = strings.ToLower(sum)
+	if !ok || algo != "sha256" || len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("archives must be pinned with 'sha256=<checksum>', got '%s'", ref)
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", fmt.Errorf("invalid sha256 checksum '%s'", sum)
+	}
+	return sum, nil
+}
+
+// archiveVersion guesses the version of an archive from its file name: the
+// part starting at the first digit that follows a '-', '_' or 'v'. Without
+// one, the file name itself is used.
+func archiveVersion(url string) string {
+	name := archiveName(url)
+	name = name[:len(name)-len(archiveExt(url))]
+	for i, r := range name {
+		if unicode.IsDigit(r) && (i == 0 || strings.ContainsRune("-_vV", rune(name[i-1]))) {
+			return name[i:]
+		}
+	}
+	return name
+}
+
+// unpack returns the directory holding the contents of the archive with the
+// given checksum, downloading and unpacking it on first use.
+func (a *Archives) unpack(url, sum string, out io.Writer) (string, error) {
+	a.mu.Lock()
+	u, ok := a.unpacks[sum]
+	if !ok {
+		u = &unpack{dir: filepath.Join(config.GetArchiveDir(), sum)}
+		a.unpacks[sum] = u
+	}
+	a.mu.Unlock()
+
+	u.once.Do(func() {
+		if _, err := os.Stat(u.dir); err == nil {
+			return
+		}
+		if git.IsOffline() {
+			u.err = fmt.Errorf("%w: %s has never been downloaded", git.ErrOffline, url)
+			return
+		}
+		fmt.Fprintf(out, "  -> Downloading %s\n", url)
+		u.err = download(url, sum, u.dir)
+	})
+	return u.dir, u.err
+}
+
+// download fetches an archive, checks its sha256 and unpacks it into dir.
+// If every entry lives under one top-level directory, that directory
+// becomes the root of the package.
+func download(url, sum, dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(dir), sum+"-*"+archiveExt(url))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	body, err := openURL(url)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, h), body)
+	body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("checksum mismatch for %s: expected sha256=%s, got sha256=%s", url, sum, got)
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), sum+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := extract(file, archiveExt(url), tmp); err != nil {
+		return fmt.Errorf("failed to unpack %s: %w", url, err)
+	}
+
+	root := tmp
+	if entries, err := os.ReadDir(tmp); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		root = filepath.Join(tmp, entries[0].Name())
+		if err := utils.CheckLinks(root); err != nil {
+			return fmt.Errorf("failed to unpack %s: %w", url, err)
+		}
+	}
+	if err := os.Rename(root, dir); err != nil {
+		// Another run unpacked the same archive first.
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// openURL opens an http(s) or file:// URL for reading.
+func openURL(url string) (io.ReadCloser, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "file" {
+		return os.Open(u.Path)
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// extract unpacks a downloaded archive into dest according to its extension.
+func extract(file *os.File, ext, dest string) error {
+	if ext == ".zip" {
+		return utils.ExtractZip(file.Name(), dest)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var r io.Reader = file
+	if ext != ".tar" {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return utils.ExtractTar(r, dest)
+}
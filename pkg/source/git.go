@@ -0,0 +1,202 @@
+// File: cpp-package-manager/pkg/source/git.go
+package source
+
+import (
+	"cpp-package-manager/pkg/config"
+	"cpp-package-manager/pkg/git"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Git opens git repositories through bare mirrors kept in the user cache.
+// Each mirror is fetched at most once per Git, so that tag listing, manifest
+// reads and installs share one download. It is safe for concurrent use.
+type Git struct {
+	// progress receives git's progress output, or nil to discard it.
+	progress io.Writer
+
+	mu      sync.Mutex
+	mirrors map[string]*mirror
+}
+
+type mirror struct {
+	once sync.Once
+	err  error
+}
+
+// NewGit returns a Git that streams git's progress output to progress,
+// which may be nil.
+func NewGit(progress io.Writer) *Git {
+	return &Git{progress: progress, mirrors: make(map[string]*mirror)}
+}
+
+// Open returns the source of the git repository at url.
+func (g *Git) Open(url string) (Source, error) {
+	return &gitSource{g: g, url: url}, nil
+}
+
+// mirrorPath returns where the bare mirror of url lives in the user cache.
+// Every spelling of the same repository shares one mirror.
+func mirrorPath(url string) string {
+	sum := sha256.Sum256([]byte(Identity(url)))
+	return filepath.Join(config.GetMirrorDir(), hex.EncodeToString(sum[:12])+".git")
+}
+
+// repo returns the path of the up-to-date mirror of url, cloning or fetching
+// it on first use.
+func (g *Git) repo(url string, out io.Writer) (string, error) {
+	g.mu.Lock()
+	m, ok := g.mirrors[Identity(url)]
+	if !ok {
+		m = &mirror{}
+		g.mirrors[Identity(url)] = m
+	}
+	g.mu.Unlock()
+
+	path := mirrorPath(url)
+	m.once.Do(func() {
+		if _, err := os.Stat(path); err == nil {
+			if !git.IsOffline() {
+				fmt.Fprintf(out, "  -> Updating %s\n", url)
+			}
+		} else if !git.IsOffline() {
+			fmt.Fprintf(out, "  -> Downloading %s\n", url)
+		}
+		m.err = git.Mirror(url, path, g.progress)
+	})
+	return path, m.err
+}
+
+// repoAt returns a mirror of url that contains commit. Commits never change,
+// so a mirror that already has it is used as-is without fetching.
+func (g *Git) repoAt(url, commit string, out io.Writer) (string, error) {
+	if path := mirrorPath(url); git.HasCommit(path, commit) {
+		return path, nil
+	}
+	return g.repo(url, out)
+}
+
+type gitSource struct {
+	g   *Git
+	url string
+}
+
+func (s *gitSource) ID() string {
+	return s.url
+}
+
+// Versions lists the tags of the repository. Matching constraints only needs
+// the tag list, so it asks the remote for it rather than fetching any
+// objects. Offline, the tags known to the mirror are the best we have.
+func (s *gitSource) Versions() ([]Revision, error) {
+	var commits map[string]string
+	if git.IsOffline() {
+		repo, err := s.g.repo(s.url, io.Discard)
+		if err != nil {
+			return nil, err
+		}
+		if commits, err = git.ListTagCommits(repo); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if commits, err = git.ListRemoteTags(s.url); err != nil {
+			return nil, fmt.Errorf("failed to list tags: %w", err)
+		}
+	}
+
+	revs := make([]Revision, 0, len(commits))
+	for tag, commit := range commits {
+		revs = append(revs, Revision{Version: tag, Commit: commit})
+	}
+	return revs, nil
+}
+
+// Resolve pins a ref to a commit. The ref may select what it names
+// explicitly, as in "tag=v1", "branch=main" or "commit=<sha>"; a bare ref is
+// looked up as a tag first and then as a commit or branch. A branch resolves
+// to its current head.
+func (s *gitSource) Resolve(ref string, out io.Writer) (Revision, error) {
+	kind, name, ok := strings.Cut(ref, "=")
+	if !ok {
+		kind, name = "", ref
+	}
+
+	var repo string
+	var err error
+	if kind == "commit" && isFullCommit(name) {
+		repo, err = s.g.repoAt(s.url, name, out)
+	} else {
+		repo, err = s.g.repo(s.url, out)
+	}
+	if err != nil {
+		return Revision{}, err
+	}
+
+	var commit string
+	switch kind {
+	case "":
+		commit, err = git.GetCommitHash(repo, name)
+	case "tag":
+		if commit, err = git.RevParse(repo, "refs/tags/"+name); err != nil {
+			err = fmt.Errorf("tag %s not found in %s", name, s.url)
+		}
+	case "branch":
+		if commit, err = git.RevParse(repo, "refs/heads/"+name); err != nil {
+			err = fmt.Errorf("branch %s not found in %s", name, s.url)
+		}
+	case "commit":
+		commit, err = git.RevParse(repo, name)
+		if err != nil && isFullCommit(name) {
+			if err = git.FetchCommit(repo, name); err == nil {
+				commit, err = git.RevParse(repo, name)
+			}
+		}
+		if err != nil && !errors.Is(err, git.ErrOffline) {
+			err = fmt.Errorf("commit %s not found in %s", name, s.url)
+		}
+	default:
+		err = fmt.Errorf("unknown selector '%s=' in '%s', expected tag=, branch= or commit=", kind, ref)
+	}
+	if err != nil {
+		return Revision{}, err
+	}
+	if kind == "tag" {
+		// A tag is its own version, so that it can still meet semver ranges.
+		return Revision{Version: name, Commit: commit}, nil
+	}
+	return Revision{Version: ref, Commit: commit}, nil
+}
+
+// isFullCommit reports whether s is a complete hexadecimal commit hash.
+func isFullCommit(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func (s *gitSource) Manifest(rev Revision, out io.Writer) ([]byte, error) {
+	repo, err := s.g.repoAt(s.url, rev.Commit, out)
+	if err != nil {
+		return nil, err
+	}
+	return git.ReadFile(repo, rev.Commit, config.ConfigFile)
+}
+
+// Fetch writes the tree of the commit into dest, without any git metadata.
+func (s *gitSource) Fetch(rev Revision, dest string, out io.Writer) error {
+	repo, err := s.g.repoAt(s.url, rev.Commit, out)
+	if err != nil {
+		return err
+	}
+	return git.Archive(repo, rev.Commit, dest)
+}
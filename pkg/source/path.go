@@ -0,0 +1,89 @@
+// File: cpp-package-manager/pkg/source/path.go
+package source
+
+import (
+	"cpp-package-manager/pkg/config"
+	"cpp-package-manager/pkg/git"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathPrefix marks a dependency on a directory of the local disk, e.g.
+// "path:../libs/mylib". Relative paths are relative to the project root.
+const PathPrefix = "path:"
+
+// IsPath reports whether url refers to a local directory.
+func IsPath(url string) bool {
+	return strings.HasPrefix(url, PathPrefix)
+}
+
+// Local is implemented by sources that live on the local disk. Their
+// directory can be linked into cpp_modules rather than copied, so that edits
+// show up without reinstalling.
+type Local interface {
+	Dir() string
+}
+
+// OpenPath returns the source of a "path:" dependency.
+func OpenPath(url string) (Source, error) {
+	dir := filepath.Clean(strings.TrimPrefix(url, PathPrefix))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &pathSource{url: url, dir: dir}, nil
+}
+
+type pathSource struct {
+	url string
+	dir string
+}
+
+func (s *pathSource) ID() string {
+	return s.url
+}
+
+func (s *pathSource) Dir() string {
+	return s.dir
+}
+
+// Versions returns the one version of a local directory: the version in its
+// cppkg.json, or "local" if it does not declare one.
+func (s *pathSource) Versions() ([]Revision, error) {
+	rev, err := s.Resolve("", io.Discard)
+	if err != nil {
+		return nil, err
+	}
+	return []Revision{rev}, nil
+}
+
+// Resolve ignores ref: a local directory only has its current contents.
+func (s *pathSource) Resolve(ref string, out io.Writer) (Revision, error) {
+	data, err := s.Manifest(Revision{}, out)
+	if err != nil || data == nil {
+		return Revision{Version: "local"}, err
+	}
+	cfg, err := config.ParseConfig(data)
+	if err != nil {
+		return Revision{}, fmt.Errorf("could not read %s: %w", filepath.Join(s.dir, config.ConfigFile), err)
+	}
+	if cfg.Version == "" {
+		return Revision{Version: "local"}, nil
+	}
+	return Revision{Version: cfg.Version}, nil
+}
+
+func (s *pathSource) Manifest(rev Revision, out io.Writer) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, config.ConfigFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *pathSource) Fetch(rev Revision, dest string, out io.Writer) error {
+	return git.CopyDir(s.dir, dest)
+}
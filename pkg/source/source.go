@@ -0,0 +1,115 @@
+// File: cpp-package-manager/pkg/source/source.go
+package source
+
+import (
+	"io"
+	neturl "net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Revision is a version of a package together with the exact revision it is
+// locked to: a commit for git, or the checksum of a release archive.
+type Revision struct {
+	Version  string
+	Commit   string
+	Checksum string
+}
+
+// Source is where a package comes from. The resolver only talks to packages
+// through this interface, so new kinds of sources can be added without
+// touching it. Implementations must be safe for concurrent use.
+type Source interface {
+	// ID is the identity of the source recorded in cppkg.lock.
+	ID() string
+	// Versions lists every tagged version of the package, in no particular
+	// order.
+	Versions() ([]Revision, error)
+	// Resolve pins a ref that is not a version range, such as a tag or a
+	// commit, to a revision.
+	Resolve(ref string, out io.Writer) (Revision, error)
+	// Manifest returns the package's cppkg.json at a revision, or nil if it
+	// has none.
+	Manifest(rev Revision, out io.Writer) ([]byte, error)
+	// Fetch writes the package's files at a revision into dest.
+	Fetch(rev Revision, dest string, out io.Writer) error
+}
+
+// Opener returns the source of a dependency URL.
+type Opener func(url string) (Source, error)
+
+// NewOpener returns an Opener that hands local directories to OpenPath,
+// release archives to an Archives and everything else to a Git, which
+// streams its progress to progress.
+func NewOpener(progress io.Writer) Opener {
+	g, a := NewGit(progress), NewArchives()
+	return func(url string) (Source, error) {
+		if IsPath(url) {
+			return OpenPath(url)
+		}
+		if IsArchive(url) {
+			return a.Open(url)
+		}
+		return g.Open(url)
+	}
+}
+
+// PackageName derives a package name from a dependency URL: the directory
+// name for local paths, the repository name for git, or the file name
+// without extension and version for archives.
+func PackageName(url string) string {
+	if IsPath(url) {
+		return filepath.Base(filepath.Clean(strings.TrimPrefix(url, PathPrefix)))
+	}
+	if IsArchive(url) {
+		name := archiveName(url)
+		name = name[:len(name)-len(archiveExt(url))]
+		if version := archiveVersion(url); version != name {
+			name = strings.TrimRight(strings.TrimSuffix(name, version), "-_vV")
+		}
+		return name
+	}
+	return strings.TrimSuffix(filepath.Base(url), ".git")
+}
+
+// Identity returns a canonical form of a dependency URL, so that different
+// spellings of the same repository compare equal. For example,
+// "https://github.com/x/y", "https://github.com/x/y.git" and
+// "git@github.com:x/y.git" all become "github.com/x/y". Users, ports and
+// letter case of the host are ignored.
+func Identity(url string) string {
+	if IsPath(url) {
+		return PathPrefix + filepath.ToSlash(filepath.Clean(strings.TrimPrefix(url, PathPrefix)))
+	}
+	if !strings.Contains(url, "://") {
+		// scp-like syntax: [user@]host:path.
+		host, repo, ok := strings.Cut(url, ":")
+		if !ok || strings.Contains(host, "/") {
+			return trimRepo(url)
+		}
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		return strings.ToLower(host) + "/" + trimRepo(strings.TrimPrefix(repo, "/"))
+	}
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return url
+	}
+	if u.Scheme == "file" {
+		return "file://" + trimRepo(path.Clean(u.Path))
+	}
+	if IsArchive(url) {
+		// The query of a download link may matter; keep everything but the
+		// host's letter case.
+		u.Host = strings.ToLower(u.Host)
+		return u.String()
+	}
+	return strings.ToLower(u.Hostname()) + "/" + trimRepo(strings.TrimPrefix(u.Path, "/"))
+}
+
+// trimRepo drops a trailing slash and ".git" from a repository path.
+func trimRepo(p string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(p, "/"), ".git")
+}
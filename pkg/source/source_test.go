@@ -0,0 +1,47 @@
+// File: cpp-package-manager/pkg/source/source_test.go
+package source
+
+import "testing"
+
+func TestIdentity(t *testing.T) {
+	tests := []struct {
+		urls []string
+		want string
+	}{
+		{[]string{
+			"https://github.com/x/y",
+			"https://github.com/x/y.git",
+			"https://github.com/x/y/",
+			"https://GitHub.com/x/y.git",
+			"https://user@github.com:443/x/y",
+			"ssh://git@github.com/x/y.git",
+			"git@github.com:x/y.git",
+			"github.com:x/y",
+		}, "github.com/x/y"},
+		{[]string{"file:///repos/y", "file:///repos/y.git", "file:///repos/./y/"}, "file:///repos/y"},
+		{[]string{"path:libs/y", "path:./libs/y/", "path:libs/z/../y"}, "path:libs/y"},
+		{[]string{"https://Host/z-1.3.tar.gz?x=1"}, "https://host/z-1.3.tar.gz?x=1"},
+	}
+	for _, tt := range tests {
+		for _, url := range tt.urls {
+			if got := Identity(url); got != tt.want {
+				t.Errorf("Identity(%q) = %q, want %q", url, got, tt.want)
+			}
+		}
+	}
+	if Identity("https://github.com/x/y") == Identity("https://github.com/fork/y") {
+		t.Error("forks must not share an identity")
+	}
+}
+
+func TestMirrorPath(t *testing.T) {
+	a := mirrorPath("https://github.com/x/a")
+	for _, url := range []string{"git@github.com:x/a.git", "ssh://git@github.com/x/a", "https://GitHub.com/x/a/"} {
+		if got := mirrorPath(url); got != a {
+			t.Errorf("mirrorPath(%s) = %s, want the mirror of https://github.com/x/a, %s", url, got, a)
+		}
+	}
+	if mirrorPath("https://github.com/y/a") == a {
+		t.Error("two repositories share a mirror")
+	}
+}
@@ -0,0 +1,169 @@
+// File: cpp-package-manager/pkg/spec/spec.go
+package spec
+
+import (
+	"cpp-package-manager/pkg/source"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Selectors name what a dependency is pinned to, as in "url#branch=main".
+const (
+	Tag    = "tag"
+	Branch = "branch"
+	Commit = "commit"
+	SHA256 = "sha256"
+)
+
+// Spec is a parsed dependency string of the form "url[#version]". The
+// version is a semver range, a bare tag or commit, or a selector such as
+// "branch=main". A '#' that belongs to the URL is escaped as "\#".
+type Spec struct {
+	URL string
+	// Selector is one of the selector constants, or "" for a semver range or
+	// a bare ref.
+	Selector string
+	// Ref is the range, tag, branch, commit or checksum. It is empty when
+	// the version was omitted.
+	Ref string
+}
+
+// Parse parses and validates a dependency string.
+func Parse(s string) (Spec, error) {
+	if strings.TrimSpace(s) == "" {
+		return Spec{}, fmt.Errorf("empty package spec")
+	}
+	url, version, hasVersion := splitUnescaped(s)
+	if url == "" {
+		return Spec{}, fmt.Errorf("missing URL in '%s'", s)
+	}
+	if strings.ContainsAny(url, " \t\n") {
+		return Spec{}, fmt.Errorf("URL '%s' contains whitespace", url)
+	}
+	if err := validateURL(url); err != nil {
+		return Spec{}, err
+	}
+	if hasVersion && version == "" {
+		return Spec{}, fmt.Errorf("missing version after '#' in '%s'", s)
+	}
+
+	sp := Spec{URL: url, Ref: version}
+	if kind, ref, ok := strings.Cut(version, "="); ok && !strings.ContainsAny(kind, "<>!~^ ") {
+		if ref == "" {
+			return Spec{}, fmt.Errorf("missing value after '%s=' in '%s'", kind, s)
+		}
+		sp.Selector, sp.Ref = kind, ref
+	}
+	return sp, sp.validate()
+}
+
+// schemes lists the URL schemes a dependency may use.
+var schemes = map[string]bool{"http": true, "https": true, "ssh": true, "git": true, "file": true}
+
+// validateURL accepts URLs with one of the allowed schemes, scp-like
+// "[user@]host:path" URLs and local "path:" directories. A URL may not start
+// with '-', where git would read it as an option.
+func validateURL(url string) error {
+	if strings.HasPrefix(url, "-") {
+		return fmt.Errorf("URL '%s' must not start with '-'", url)
+	}
+	if source.IsPath(url) {
+		return nil
+	}
+	if scheme, _, ok := strings.Cut(url, "://"); ok {
+		if !schemes[strings.ToLower(scheme)] {
+			return fmt.Errorf("unsupported URL scheme '%s://' in '%s', expected http, https, ssh, git or file", scheme, url)
+		}
+		return nil
+	}
+	// "transport::address" would select a git remote helper.
+	host, repo, ok := strings.Cut(url, ":")
+	if !ok || host == "" || strings.Contains(host, "/") || strings.HasPrefix(repo, ":") {
+		return fmt.Errorf("'%s' is not a URL, expected e.g. https://host/repo, git@host:repo or path:dir", url)
+	}
+	return nil
+}
+
+// splitUnescaped splits s at its first '#' that is not escaped as "\#", and
+// unescapes the URL part.
+func splitUnescaped(s string) (url, version string, found bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '#':
+			b.WriteByte('#')
+			i++
+		case s[i] == '#':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), "", false
+}
+
+func (sp Spec) validate() error {
+	path := source.IsPath(sp.URL)
+	archive := source.IsArchive(sp.URL)
+	switch sp.Selector {
+	case "":
+		if archive {
+			return fmt.Errorf("archive %s must be pinned with '#sha256=<checksum>'", sp.URL)
+		}
+		if path && sp.Ref != "" {
+			if _, err := semver.NewConstraint(sp.Ref); err != nil {
+				return fmt.Errorf("local path %s can only take a version range, got '%s'", sp.URL, sp.Ref)
+			}
+		}
+		if strings.ContainsAny(sp.Ref, "#\\") {
+			return fmt.Errorf("invalid version '%s'", sp.Ref)
+		}
+	case Tag, Branch, Commit:
+		if path || archive {
+			return fmt.Errorf("'%s=' only applies to git repositories, not %s", sp.Selector, sp.URL)
+		}
+		if strings.ContainsAny(sp.Ref, " \t#\\") {
+			return fmt.Errorf("invalid %s '%s'", sp.Selector, sp.Ref)
+		}
+		if sp.Selector == Commit {
+			if !isHex(sp.Ref) || len(sp.Ref) < 4 {
+				return fmt.Errorf("invalid commit '%s', expected a hexadecimal hash", sp.Ref)
+			}
+		}
+	case SHA256:
+		if !archive {
+			return fmt.Errorf("'sha256=' only applies to archives, not %s", sp.URL)
+		}
+		if !isHex(sp.Ref) || len(sp.Ref) != 64 {
+			return fmt.Errorf("invalid sha256 checksum '%s', expected 64 hexadecimal digits", sp.Ref)
+		}
+	default:
+		return fmt.Errorf("unknown selector '%s=', expected tag=, branch=, commit= or sha256=", sp.Selector)
+	}
+	return nil
+}
+
+// isHex reports whether s consists of hexadecimal digits only.
+func isHex(s string) bool {
+	return strings.Trim(strings.ToLower(s), "0123456789abcdef") == ""
+}
+
+// Constraint returns the version as the resolver matches it: the range or
+// bare ref, or "selector=ref".
+func (sp Spec) Constraint() string {
+	if sp.Selector == "" {
+		return sp.Ref
+	}
+	return sp.Selector + "=" + sp.Ref
+}
+
+// String formats the spec as it is written in cppkg.json.
+func (sp Spec) String() string {
+	url := strings.ReplaceAll(sp.URL, "#", `\#`)
+	if c := sp.Constraint(); c != "" {
+		return url + "#" + c
+	}
+	return url
+}
@@ -0,0 +1,69 @@
+// File: cpp-package-manager/pkg/spec/spec_test.go
+package spec
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	sum := "9a93b2b7dfdac77ceba5a558a580e74667dd6fede4585b91eefb60f03b72df23"
+	tests := []struct {
+		in      string
+		want    Spec
+		wantErr bool
+	}{
+		{in: "https://github.com/fmtlib/fmt.git#^10.0.0", want: Spec{URL: "https://github.com/fmtlib/fmt.git", Ref: "^10.0.0"}},
+		{in: "https://github.com/fmtlib/fmt.git", want: Spec{URL: "https://github.com/fmtlib/fmt.git"}},
+		{in: "git@github.com:fmtlib/fmt.git#v10.2.1", want: Spec{URL: "git@github.com:fmtlib/fmt.git", Ref: "v10.2.1"}},
+		{in: "ssh://git@host/x/y#>=1.0.0, <2.0.0", want: Spec{URL: "ssh://git@host/x/y", Ref: ">=1.0.0, <2.0.0"}},
+		{in: "https://host/x/y#branch=main", want: Spec{URL: "https://host/x/y", Selector: Branch, Ref: "main"}},
+		{in: "https://host/x/y#tag=release-1", want: Spec{URL: "https://host/x/y", Selector: Tag, Ref: "release-1"}},
+		{in: "https://host/x/y#commit=0e5ae75437", want: Spec{URL: "https://host/x/y", Selector: Commit, Ref: "0e5ae75437"}},
+		{in: "https://host/z-1.3.tar.gz#sha256=" + sum, want: Spec{URL: "https://host/z-1.3.tar.gz", Selector: SHA256, Ref: sum}},
+		{in: `file:///repos/c\#/lib#^1.0.0`, want: Spec{URL: "file:///repos/c#/lib", Ref: "^1.0.0"}},
+		{in: "path:../libs/mylib", want: Spec{URL: "path:../libs/mylib"}},
+		{in: "path:../libs/mylib#^1.0.0", want: Spec{URL: "path:../libs/mylib", Ref: "^1.0.0"}},
+
+		{in: "", wantErr: true},
+		{in: "#^1.0.0", wantErr: true},
+		{in: "https://host/x/y#", wantErr: true},
+		{in: "https://host/x y#^1.0.0", wantErr: true},
+		{in: "-j", wantErr: true},
+		{in: "--upload-pack=touch${IFS}/tmp/pwned;git-upload-pack#^1.0.0", wantErr: true},
+		{in: "ext::sh#^1.0.0", wantErr: true},
+		{in: "ftp://host/x/y#^1.0.0", wantErr: true},
+		{in: "fmt#^1.0.0", wantErr: true},
+		{in: "https://host/x/y#brnach=main", wantErr: true},
+		{in: "https://host/x/y#branch=", wantErr: true},
+		{in: "https://host/x/y#commit=main", wantErr: true},
+		{in: "https://host/x/y#sha256=" + sum, wantErr: true},
+		{in: "https://host/z-1.3.tar.gz#^1.0.0", wantErr: true},
+		{in: "https://host/z-1.3.tar.gz#sha256=abc", wantErr: true},
+		{in: "path:../libs/mylib#branch=main", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSpecString(t *testing.T) {
+	for _, in := range []string{
+		"https://host/x/y#^1.0.0",
+		"https://host/x/y#branch=main",
+		`file:///repos/c\#/lib#^1.0.0`,
+		"path:../libs/mylib",
+	} {
+		sp, err := Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", in, err)
+		}
+		if got := sp.String(); got != in {
+			t.Errorf("Parse(%q).String() = %q", in, got)
+		}
+	}
+}
@@ -7,10 +7,21 @@ type PackageConfig struct {
 	Version      string            `json:"version"`
 	Dependencies map[string]string `json:"dependencies"`
 	Scripts      map[string]string `json:"scripts,omitempty"` // For post-install hooks
+	// AllowCycles permits dependencies that depend on each other in a cycle.
+	AllowCycles bool `json:"allowCycles,omitempty"`
 }
 
+// LockfileVersion is the format version of the cppkg.lock files written by
+// this cppkg. Files without a version predate the dependency graph.
+const LockfileVersion = 2
+
+// LockfileGraphVersion is the format version that added the dependency
+// graph, Root and Dependencies, to cppkg.lock.
+const LockfileGraphVersion = 2
+
 // LockFile matches the structure of cppkg.lock
 type LockFile struct {
+	Version      int                         `json:"version,omitempty"`
 	Dependencies map[string]LockedDependency `json:"dependencies"`
 }
 
@@ -18,5 +29,26 @@ type LockFile struct {
 type LockedDependency struct {
 	URL     string `json:"url"`
 	Version string `json:"version"`
-	Commit  string `json:"commit"`
+	Commit  string `json:"commit,omitempty"`
+	// Checksum pins a release archive, which has no commit, e.g. "sha256=<hex>".
+	Checksum string `json:"checksum,omitempty"`
+	// Integrity is a digest of the package's file tree, computed at install time.
+	Integrity string `json:"integrity,omitempty"`
+	// Root marks a package that cppkg.json requires directly.
+	Root bool `json:"root,omitempty"`
+	// Dependencies maps every package this one requires to its constraint.
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+}
+
+// InstallManifest records what is currently installed in cpp_modules, so that
+// an install only has to touch the packages that changed.
+type InstallManifest struct {
+	Packages map[string]InstalledPackage `json:"packages"`
+}
+
+// InstalledPackage stores the commit and content hash of an installed package.
+type InstalledPackage struct {
+	// Commit is the installed commit, or the checksum of an archive.
+	Commit string `json:"commit"`
+	Hash   string `json:"hash"`
 }
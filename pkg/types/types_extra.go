@@ -1,7 +0,0 @@
-// File: pkg/types/types_extra.go
-package types
-
-type DiscoveryResult struct {
-	Urls        map[string]string
-	Constraints map[string][]string
-}
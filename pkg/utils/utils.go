@@ -1,10 +1,241 @@
 // File: pkg/utils/utils.go
 package utils
 
-import "strings"
+import (
+	"archive/tar"
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
 
-// ParsePkgStr splits a package string of the form 'url#version' into url and version/constraint.
-func ParsePkgStr(pkgStr string) (url, constraint string) {
-	parts := strings.Split(pkgStr, "#")
-	return parts[0], parts[1]
+// HashDir returns a digest of a directory tree. It only depends on the
+// relative paths, contents, symlink targets and executable bits of the files
+// in it, so identical trees hash identically wherever they live.
+func HashDir(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "link %s %s\n", filepath.ToSlash(rel), target)
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "file %s %t %x\n", filepath.ToSlash(rel), info.Mode()&0111 != 0, sha256.Sum256(data))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return "sha256-" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ExtractTar unpacks a tar stream into dest, refusing entries that would
+// escape it.
+func ExtractTar(r io.Reader, dest string) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := extractTarget(dest, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := writeSymlink(dest, target, hdr.Linkname); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ExtractZip unpacks a zip file into dest, refusing entries that would
+// escape it.
+func ExtractZip(path, dest string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		target, err := extractTarget(dest, f.Name)
+		if err != nil {
+			return err
+		}
+		mode := f.Mode()
+		if mode.IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		if mode&fs.ModeSymlink != 0 {
+			var link []byte
+			if link, err = io.ReadAll(rc); err == nil {
+				err = writeSymlink(dest, target, string(link))
+			}
+		} else {
+			perm := mode.Perm()
+			if perm == 0 {
+				perm = 0644
+			}
+			err = writeFile(target, rc, perm)
+		}
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractTarget returns where an archive entry is extracted to, or an error
+// if it would land outside dest. Entries may not pass through a symlink
+// extracted before them, which could point anywhere.
+func extractTarget(dest, name string) (string, error) {
+	target := filepath.Join(dest, name)
+	rel, err := filepath.Rel(dest, target)
+	if err != nil || escapes(rel) {
+		return "", fmt.Errorf("archive entry %s escapes destination", name)
+	}
+	if rel == "." {
+		return target, nil
+	}
+	dir := dest
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("archive entry %s goes through symlink %s", name, part)
+		}
+	}
+	return target, nil
+}
+
+// escapes reports whether a path relative to some directory leaves it.
+func escapes(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel)
+}
+
+// checkLink refuses a symlink at target, inside dest, whose link is absolute
+// or climbs out of dest. A ".." is only allowed before any other component:
+// after one, it would climb out of whatever that component links to, which
+// may not be where it appears to be.
+func checkLink(dest, target, link string) error {
+	rel, err := filepath.Rel(dest, filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	depth := 0
+	if rel != "." {
+		depth = len(strings.Split(rel, string(filepath.Separator)))
+	}
+	if filepath.IsAbs(link) || strings.HasPrefix(link, "/") {
+		return fmt.Errorf("symlink %s points to absolute path %s", target, link)
+	}
+	descended := false
+	for _, part := range strings.Split(filepath.ToSlash(link), "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if descended || depth == 0 {
+				return fmt.Errorf("symlink %s points outside the archive: %s", target, link)
+			}
+			depth--
+		default:
+			descended = true
+			depth++
+		}
+	}
+	return nil
+}
+
+// CheckLinks refuses any symlink under root that points outside it. Links
+// are checked against dest while extracting, so use this when root is a
+// subdirectory of dest, such as a stripped top-level directory.
+func CheckLinks(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.Type()&fs.ModeSymlink == 0 {
+			return err
+		}
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		return checkLink(root, path, link)
+	})
+}
+
+func writeSymlink(dest, target, link string) error {
+	if err := checkLink(dest, target, link); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.Symlink(link, target)
+}
+
+func writeFile(target string, r io.Reader, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
@@ -0,0 +1,83 @@
+// File: pkg/utils/utils_test.go
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type tarEntry struct {
+	name string
+	link string // a symlink if not empty, else a file
+}
+
+func tarOf(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: 1}
+		if e.link != "" {
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if e.link == "" {
+			tw.Write([]byte("x"))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractTar(t *testing.T) {
+	outside := t.TempDir()
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		wantErr bool
+	}{
+		{"plain files", []tarEntry{{name: "include/s.h"}, {name: "src/s.c"}}, false},
+		{"link to sibling", []tarEntry{{name: "include/s.h"}, {name: "include/alias.h", link: "s.h"}}, false},
+		{"link up within archive", []tarEntry{{name: "src/s.c"}, {name: "include/s.c", link: "../src/s.c"}}, false},
+		{"link to directory", []tarEntry{{name: "include/s.h"}, {name: "inc", link: "include"}}, false},
+		{"dotdot entry", []tarEntry{{name: "../pwned.txt"}}, true},
+		{"absolute link", []tarEntry{{name: "esc", link: outside}}, true},
+		{"relative link out", []tarEntry{{name: "a/esc", link: "../../out"}}, true},
+		{"dotdot after component", []tarEntry{{name: "d", link: "."}, {name: "e", link: "d/.."}}, true},
+		{"write through link", []tarEntry{{name: "sub/x"}, {name: "esc", link: "sub"}, {name: "esc/pwned.txt"}}, true},
+		{"overwrite link", []tarEntry{{name: "sub/x"}, {name: "esc", link: "sub/x"}, {name: "esc"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "dest")
+			err := ExtractTar(tarOf(t, tt.entries), dest)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtractTar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("files were written outside the destination: %v", entries)
+	}
+}
+
+func TestCheckLinks(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "dest")
+	entries := []tarEntry{{name: "top/x"}, {name: "top/ok", link: "x"}, {name: "top/evil", link: "../other"}}
+	if err := ExtractTar(tarOf(t, entries), dest); err != nil {
+		t.Fatalf("ExtractTar() error = %v", err)
+	}
+	if err := CheckLinks(dest); err != nil {
+		t.Errorf("CheckLinks(dest) error = %v, want nil", err)
+	}
+	if err := CheckLinks(filepath.Join(dest, "top")); err == nil {
+		t.Error("CheckLinks(dest/top) accepted top/evil -> ../other, which escapes once top is stripped")
+	}
+}